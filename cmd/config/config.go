@@ -8,7 +8,7 @@ import (
 	"github.com/thegrumpyape/umbrellasync/pkg/configurationManager"
 )
 
-var ConfigAvailableKeys = []string{"apihostname", "apiversion", "key", "secret", "files"}
+var ConfigAvailableKeys = []string{"apihostname", "apiversion", "key", "secret", "files", "shardsize", "bundletypes", "cachemaxage", "sources", "protecteddomains", "protectedcidrs", "failonprotected", "popularitythreshold", "popularityallow", "compact", "compactsiblings", "targets", "routes", "rules"}
 
 type ConfigCommandDependencies struct {
 	ConfigurationManager *configurationManager.ConfigurationManager
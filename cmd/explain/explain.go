@@ -0,0 +1,98 @@
+package explain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thegrumpyape/umbrellasync/pkg/stateManager"
+)
+
+type ExplainCommandDependencies struct {
+	StateManager *stateManager.StateManager
+}
+
+func New(deps *ExplainCommandDependencies) *cobra.Command {
+	explainCmd := &cobra.Command{
+		Use:   "explain",
+		Short: "Explain why a destination is synced",
+		Long:  "Show the sources asserting a destination and the targets it is synced to, as recorded by the last sync",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return explain(deps, args[0])
+		},
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("requires exactly 1 argument")
+			}
+			return nil
+		},
+	}
+
+	return explainCmd
+}
+
+func explain(deps *ExplainCommandDependencies, destination string) error {
+	state, err := deps.StateManager.Load()
+	if err != nil {
+		return err
+	}
+
+	value := strings.TrimSpace(destination)
+	if !strings.Contains(value, "/") {
+		value = strings.TrimSuffix(strings.ToLower(value), ".")
+	}
+
+	found := false
+	for _, name := range sortedKeys(state.Sources) {
+		record, ok := state.Sources[name].Indicators[value]
+		if !ok {
+			continue
+		}
+		found = true
+		fmt.Printf("Asserted by source %s, first seen %s, last seen %s\n", name, formatDate(record.FirstSeen), formatDate(record.LastSeen))
+	}
+
+	for _, name := range sortedKeys(state.Targets) {
+		record, ok := state.Targets[name].Indicators[value]
+		if !ok {
+			continue
+		}
+		found = true
+		fmt.Printf("Synced to target %s through %s, first seen %s\n", name, strings.Join(record.Sources, ", "), formatDate(record.FirstSeen))
+	}
+
+	for _, name := range sortedKeys(state.Traces) {
+		for _, trace := range state.Traces[name] {
+			if trace.Original != value && trace.Result != value {
+				continue
+			}
+			found = true
+			if trace.Dropped() {
+				fmt.Printf("Dropped from source %s by rule %s\n", name, trace.Rule)
+				continue
+			}
+			fmt.Printf("Rewritten in source %s by rule %s from %s to %s\n", name, trace.Rule, trace.Original, trace.Result)
+		}
+	}
+
+	if !found {
+		fmt.Println(value, "is not asserted by any source as of the last sync")
+	}
+	return nil
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatDate(t time.Time) string {
+	return t.Local().Format("2006-01-02 15:04")
+}
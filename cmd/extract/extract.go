@@ -0,0 +1,60 @@
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/thegrumpyape/umbrellasync/pkg/fileManager"
+	"github.com/thegrumpyape/umbrellasync/pkg/source"
+)
+
+func New() *cobra.Command {
+	var output string
+
+	extractCmd := &cobra.Command{
+		Use:   "extract",
+		Short: "Extract indicators from a text report",
+		Long:  "Extract domains, URLs and IPs from an unstructured text report into a blocklist for review. Defanged indicators are refanged and the line each was found on is kept as a comment",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return extract(args[0], output)
+		},
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("requires exactly 1 argument")
+			}
+			return nil
+		},
+	}
+
+	extractCmd.Flags().StringVarP(&output, "output", "o", "", "write the candidates to a blocklist file instead of stdout")
+
+	return extractCmd
+}
+
+func extract(filepath string, output string) error {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	indicators, err := source.ParseExtract(source.Config{}, file)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %d candidates extracted from %s\n", len(indicators), filepath)
+	for _, indicator := range indicators {
+		fmt.Fprintf(&b, "%s  # %s\n", indicator.Value, indicator.Comment())
+	}
+
+	if output == "" {
+		fmt.Print(b.String())
+		return nil
+	}
+	return fileManager.WriteToFile(output, []byte(b.String()))
+}
@@ -0,0 +1,35 @@
+package ignore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/thegrumpyape/umbrellasync/pkg/ignoreManager"
+)
+
+func NewAddCommand(deps *IgnoreCommandDependencies) *cobra.Command {
+	var message string
+
+	addCommand := &cobra.Command{
+		Use:   "add",
+		Short: "Add a domain to the ignore list",
+		Long:  "Add a domain to the ignore list. The domain and all of its subdomains are skipped during sync",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !ignoreManager.ValidDomain(args[0]) {
+				return fmt.Errorf("%q is not a domain", args[0])
+			}
+			return deps.IgnoreManager.Add(args[0], ignoreManager.Ignored, message)
+		},
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("requires exactly 1 argument")
+			}
+			return nil
+		},
+	}
+
+	addCommand.Flags().StringVarP(&message, "message", "m", "", "reason for ignoring the domain")
+
+	return addCommand
+}
@@ -0,0 +1,51 @@
+package ignore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/thegrumpyape/umbrellasync/pkg/fileManager"
+)
+
+func NewExportCommand(deps *IgnoreCommandDependencies) *cobra.Command {
+	exportCommand := &cobra.Command{
+		Use:   "export",
+		Short: "Export the ignore list",
+		Long:  "Export the ignore list as JSON to a file, or to stdout if no file is given",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filepath := ""
+			if len(args) == 1 {
+				filepath = args[0]
+			}
+			return export(deps, filepath)
+		},
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 1 {
+				return errors.New("accepts at most 1 argument")
+			}
+			return nil
+		},
+	}
+
+	return exportCommand
+}
+
+func export(deps *IgnoreCommandDependencies, filepath string) error {
+	entries, err := deps.IgnoreManager.List()
+	if err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if filepath == "" {
+		fmt.Println(string(content))
+		return nil
+	}
+	return fileManager.WriteToFile(filepath, content)
+}
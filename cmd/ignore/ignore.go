@@ -0,0 +1,26 @@
+package ignore
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/thegrumpyape/umbrellasync/pkg/ignoreManager"
+)
+
+type IgnoreCommandDependencies struct {
+	IgnoreManager *ignoreManager.IgnoreManager
+}
+
+func New(deps *IgnoreCommandDependencies) *cobra.Command {
+	ignoreCommand := &cobra.Command{
+		Use:   "ignore",
+		Short: "Ignore list management",
+		Long:  "Manage high volume and ignored domains that are never synced to Umbrella",
+	}
+
+	ignoreCommand.AddCommand(NewListCommand(deps))
+	ignoreCommand.AddCommand(NewAddCommand(deps))
+	ignoreCommand.AddCommand(NewRemoveCommand(deps))
+	ignoreCommand.AddCommand(NewImportCommand(deps))
+	ignoreCommand.AddCommand(NewExportCommand(deps))
+
+	return ignoreCommand
+}
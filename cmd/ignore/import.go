@@ -0,0 +1,113 @@
+package ignore
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/cobra"
+	"github.com/thegrumpyape/umbrellasync/pkg/fileManager"
+	"github.com/thegrumpyape/umbrellasync/pkg/ignoreManager"
+)
+
+func NewImportCommand(deps *IgnoreCommandDependencies) *cobra.Command {
+	importCommand := &cobra.Command{
+		Use:   "import",
+		Short: "Import domains into the ignore list",
+		Long:  "Import domains from a JSON file written by export, or from a text file with one domain per line",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return importFile(deps, args[0])
+		},
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("requires exactly 1 argument")
+			}
+			return nil
+		},
+	}
+
+	return importCommand
+}
+
+func importFile(deps *IgnoreCommandDependencies, filepath string) error {
+	file, err := os.Open(filepath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// JSON exports are an array of entries and the ignore store itself is
+	// an object holding them, anything else is read line by line
+	reader := bufio.NewReader(file)
+	var entries []ignoreManager.Entry
+	first, _ := firstByte(reader)
+	switch first {
+	case '[':
+		err = json.NewDecoder(reader).Decode(&entries)
+	case '{':
+		var store struct {
+			Entries []ignoreManager.Entry `json:"entries"`
+		}
+		err = json.NewDecoder(reader).Decode(&store)
+		entries = store.Entries
+	default:
+		entries, err = readLines(reader, filepath)
+	}
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", filepath, err)
+	}
+
+	for i, entry := range entries {
+		if !ignoreManager.ValidDomain(entry.Domain) {
+			return fmt.Errorf("error reading %s: entry %d, %q, is not a domain", filepath, i+1, entry.Domain)
+		}
+	}
+
+	added, err := deps.IgnoreManager.Import(entries)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Imported", added, "domains")
+	return nil
+}
+
+// Reads one domain per line, skipping blank lines and comments
+func readLines(reader *bufio.Reader, filepath string) ([]ignoreManager.Entry, error) {
+	var entries []ignoreManager.Entry
+	scanner := fileManager.NewLineScanner(reader, 0)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, ignoreManager.Entry{
+			Domain:  line,
+			Kind:    ignoreManager.Ignored,
+			Message: "imported from " + filepath,
+		})
+	}
+	return entries, scanner.Err()
+}
+
+// Returns the first byte that is not whitespace or a byte order mark without
+// consuming it
+func firstByte(reader *bufio.Reader) (byte, error) {
+	if bom, err := reader.Peek(3); err == nil && string(bom) == "\ufeff" {
+		reader.Discard(3)
+	}
+	for {
+		b, err := reader.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		if !unicode.IsSpace(rune(b[0])) {
+			return b[0], nil
+		}
+		reader.Discard(1)
+	}
+}
@@ -0,0 +1,37 @@
+package ignore
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func NewListCommand(deps *IgnoreCommandDependencies) *cobra.Command {
+	listCommand := &cobra.Command{
+		Use:   "list",
+		Short: "List ignored domains",
+		Long:  "List high volume and ignored domains with the time and reason they were added",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return list(deps)
+		},
+	}
+
+	return listCommand
+}
+
+func list(deps *IgnoreCommandDependencies) error {
+	entries, err := deps.IgnoreManager.List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DOMAIN\tKIND\tADDED\tMESSAGE")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", entry.Domain, entry.Kind, entry.AddedAt.Format(time.RFC3339), entry.Message)
+	}
+	return w.Flush()
+}
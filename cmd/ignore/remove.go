@@ -0,0 +1,34 @@
+package ignore
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func NewRemoveCommand(deps *IgnoreCommandDependencies) *cobra.Command {
+	removeCommand := &cobra.Command{
+		Use:   "remove",
+		Short: "Remove a domain from the ignore list",
+		Long:  "Remove a domain from the ignore list so it is synced again",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			removed, err := deps.IgnoreManager.Remove(args[0])
+			if err != nil {
+				return err
+			}
+			if !removed {
+				fmt.Println(args[0], "is not in the ignore list")
+			}
+			return nil
+		},
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("requires exactly 1 argument")
+			}
+			return nil
+		},
+	}
+
+	return removeCommand
+}
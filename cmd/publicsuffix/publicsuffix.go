@@ -0,0 +1,108 @@
+package publicsuffix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thegrumpyape/umbrellasync/pkg/validation"
+)
+
+const defaultListURL = "https://publicsuffix.org/list/public_suffix_list.dat"
+
+type PublicSuffixCommandDependencies struct {
+	SuffixList *validation.SuffixList
+}
+
+func New(deps *PublicSuffixCommandDependencies) *cobra.Command {
+	publicSuffixCommand := &cobra.Command{
+		Use:   "publicsuffix",
+		Short: "Public Suffix List management",
+		Long:  "Manage the Public Suffix List used to refuse blocking public suffixes such as co.uk or github.io",
+	}
+
+	publicSuffixCommand.AddCommand(NewUpdateCommand(deps))
+	publicSuffixCommand.AddCommand(NewCheckCommand(deps))
+
+	return publicSuffixCommand
+}
+
+func NewUpdateCommand(deps *PublicSuffixCommandDependencies) *cobra.Command {
+	var listURL string
+
+	updateCmd := &cobra.Command{
+		Use:   "update",
+		Short: "Download the latest Public Suffix List",
+		Long:  "Download the latest Public Suffix List to use in place of the list built into umbrellasync",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+
+			req, err := http.NewRequestWithContext(ctx, "GET", listURL, nil)
+			if err != nil {
+				return err
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("non-OK HTTP status: %s", resp.Status)
+			}
+
+			count, err := deps.SuffixList.Update(resp.Body)
+			if err != nil {
+				return err
+			}
+			fmt.Println("Updated Public Suffix List with", count, "rules")
+			return nil
+		},
+	}
+
+	updateCmd.Flags().StringVar(&listURL, "url", defaultListURL, "URL to download the list from")
+
+	return updateCmd
+}
+
+func NewCheckCommand(deps *PublicSuffixCommandDependencies) *cobra.Command {
+	checkCmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check whether destinations can be blocked",
+		Long:  "Show the public suffix of each destination and whether sync would block it",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			listSource, err := deps.SuffixList.Source()
+			if err != nil {
+				return err
+			}
+			fmt.Println("Using", listSource, "Public Suffix List")
+
+			for _, destination := range args {
+				check, suffix, err := deps.SuffixList.Check(destination)
+				if err != nil {
+					return err
+				}
+				switch check {
+				case validation.SuffixPublic:
+					fmt.Printf("%s: refused, it is a public suffix\n", destination)
+				case validation.SuffixSharedTenant:
+					fmt.Printf("%s: allowed, but it is a tenant of the shared hosting suffix %s\n", destination, suffix)
+				default:
+					fmt.Printf("%s: allowed\n", destination)
+				}
+			}
+			return nil
+		},
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return errors.New("requires at least 1 argument")
+			}
+			return nil
+		},
+	}
+
+	return checkCmd
+}
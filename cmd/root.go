@@ -6,10 +6,20 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/thegrumpyape/umbrellasync/cmd/config"
+	"github.com/thegrumpyape/umbrellasync/cmd/explain"
+	"github.com/thegrumpyape/umbrellasync/cmd/extract"
+	"github.com/thegrumpyape/umbrellasync/cmd/ignore"
+	"github.com/thegrumpyape/umbrellasync/cmd/publicsuffix"
 	"github.com/thegrumpyape/umbrellasync/cmd/sync"
+	"github.com/thegrumpyape/umbrellasync/cmd/toplist"
 	"github.com/thegrumpyape/umbrellasync/cmd/version"
+	"github.com/thegrumpyape/umbrellasync/pkg/cacheManager"
 	"github.com/thegrumpyape/umbrellasync/pkg/configurationManager"
+	"github.com/thegrumpyape/umbrellasync/pkg/ignoreManager"
 	"github.com/thegrumpyape/umbrellasync/pkg/logging"
+	"github.com/thegrumpyape/umbrellasync/pkg/source"
+	"github.com/thegrumpyape/umbrellasync/pkg/stateManager"
+	"github.com/thegrumpyape/umbrellasync/pkg/validation"
 	"gopkg.in/natefinch/lumberjack.v2"
 )
 
@@ -39,22 +49,53 @@ func init() {
 		Compress:   true,
 	}
 
-	logger := &logrus.Logger{
+	logrusLogger := &logrus.Logger{
 		Out:       os.Stdout,
 		Formatter: &logrus.TextFormatter{ForceColors: true},
 		Hooks:     make(logrus.LevelHooks),
 		Level:     logrus.InfoLevel,
 	}
 	fileHook := logging.NewFileHook(lumberjackLogrotate, &logrus.JSONFormatter{})
-	logger.AddHook(fileHook)
-	configurationManager := configurationManager.New()
-	cobra.OnInitialize(configurationManager.InitConfigFile)
+	logrusLogger.AddHook(fileHook)
+	logger = logrusLogger
+	ignoreStore := ignoreManager.New(configurationManager.DataPath("ignore.json"))
+	listCache := cacheManager.New(configurationManager.DataPath("cache"))
+	sourceLoader := source.NewLoader(configurationManager.DataPath("sources"), logger)
+	syncState := stateManager.New(configurationManager.DataPath("state.json"))
+	suffixList := validation.NewSuffixList(configurationManager.DataPath("public_suffix_list.dat"))
+	ranking := validation.NewRanking(configurationManager.DataPath("toplist.csv"), suffixList)
+	configManager := configurationManager.New()
+	cobra.OnInitialize(configManager.InitConfigFile)
 	rootCmd.AddCommand(sync.New(&sync.SyncCommandDependencies{
-		ConfigurationManager: configurationManager,
+		ConfigurationManager: configManager,
+		IgnoreManager:        ignoreStore,
+		CacheManager:         listCache,
+		SourceLoader:         sourceLoader,
+		StateManager:         syncState,
+		SuffixList:           suffixList,
+		Ranking:              ranking,
 		Logger:               logger,
 	}))
 	rootCmd.AddCommand(config.New(&config.ConfigCommandDependencies{
-		ConfigurationManager: configurationManager,
+		ConfigurationManager: configManager,
+	}))
+
+	rootCmd.AddCommand(extract.New())
+
+	rootCmd.AddCommand(explain.New(&explain.ExplainCommandDependencies{
+		StateManager: syncState,
+	}))
+
+	rootCmd.AddCommand(ignore.New(&ignore.IgnoreCommandDependencies{
+		IgnoreManager: ignoreStore,
+	}))
+
+	rootCmd.AddCommand(publicsuffix.New(&publicsuffix.PublicSuffixCommandDependencies{
+		SuffixList: suffixList,
+	}))
+
+	rootCmd.AddCommand(toplist.New(&toplist.ToplistCommandDependencies{
+		Ranking: ranking,
 	}))
 
 	rootCmd.AddCommand(version.New(&version.VersionCommandDependencies{
@@ -63,7 +104,7 @@ func init() {
 
 	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
 		if debug {
-			logger.SetLevel(logrus.DebugLevel)
+			logrusLogger.SetLevel(logrus.DebugLevel)
 		}
 	}
 
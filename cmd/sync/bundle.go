@@ -0,0 +1,77 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/configurationManager"
+	"github.com/thegrumpyape/umbrellasync/pkg/umbrella"
+)
+
+type bundleType struct {
+	ID     int
+	Name   string
+	Prefix string
+}
+
+var bundleTypes = map[string]bundleType{
+	"dns": {ID: umbrella.BundleTypeDNS, Name: "dns", Prefix: "SOC Block "},
+	"web": {ID: umbrella.BundleTypeWeb, Name: "web", Prefix: "SOC Web Block "},
+}
+
+// Reads the bundle types lists are synced to from config.yaml. Accepts a list
+// or a comma separated string and defaults to DNS lists only.
+func configuredBundleTypes(cm *configurationManager.ConfigurationManager) ([]bundleType, error) {
+	var names []string
+	switch v := cm.Get("bundletypes").(type) {
+	case nil:
+		names = []string{"dns"}
+	case string:
+		names = strings.Split(v, ",")
+	case []interface{}:
+		for _, name := range v {
+			names = append(names, fmt.Sprint(name))
+		}
+	default:
+		return nil, fmt.Errorf("invalid type for bundletypes: %T", v)
+	}
+
+	var configured []bundleType
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		bt, ok := bundleTypes[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown bundle type %q, must be dns or web", name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			configured = append(configured, bt)
+		}
+	}
+	return configured, nil
+}
+
+// Returns the entries a list of the given bundle type can hold. URLs only go
+// to web lists, domains go to every configured bundle type.
+func routeEntries(entries []string, bt bundleType) (routed []string, skipped []string) {
+	for _, entry := range entries {
+		if bt.ID != umbrella.BundleTypeWeb && umbrella.IsURL(entry) {
+			skipped = append(skipped, entry)
+			continue
+		}
+		routed = append(routed, entry)
+	}
+	return routed, skipped
+}
+
+// Returns the lists of the given bundle type
+func filterBundleType(destinationLists []umbrella.DestinationList, bt bundleType) []umbrella.DestinationList {
+	var filtered []umbrella.DestinationList
+	for _, dl := range destinationLists {
+		if dl.BundleType() == bt.ID {
+			filtered = append(filtered, dl)
+		}
+	}
+	return filtered
+}
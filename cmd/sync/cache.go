@@ -0,0 +1,101 @@
+package sync
+
+import (
+	"time"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/cacheManager"
+	"github.com/thegrumpyape/umbrellasync/pkg/umbrella"
+)
+
+// Hours after which a list is read from Umbrella in full even if its metadata
+// is unchanged, to catch drift the metadata does not reveal
+const defaultCacheMaxAge = 24
+
+// Reads the destinations of a shard from the local cache when the list is
+// unchanged since it was cached, otherwise from Umbrella
+func fetchDestinations(deps *SyncUmbrellaDependencies, s *shard, maxAge time.Duration) error {
+	if !deps.Refresh {
+		cache, err := deps.CacheManager.Load(s.List.ID)
+		if err != nil {
+			deps.Logger.Warn("Ignoring unreadable cache for ", s.Name, ": ", err)
+		}
+		if cache != nil && cache.IsFresh(s.List, maxAge) {
+			deps.Logger.Info("Using ", len(cache.Destinations), " cached destinations for ", s.Name)
+			s.Destinations = cache.Destinations
+			s.FullFetchAt = cache.FullFetchAt
+			return nil
+		}
+	}
+
+	deps.Logger.Info("Reading ", s.List.Meta.DestinationCount, " destinations from ", s.Name)
+	destinations, err := deps.UmbrellaConnector.GetDestinations(s.List.ID, 100)
+	if err != nil {
+		return err
+	}
+	s.Destinations = destinations
+	s.FullFetchAt = time.Now().UTC()
+
+	return deps.CacheManager.Save(&cacheManager.ListCache{
+		ListID:           s.List.ID,
+		ModifiedAt:       s.List.ModifiedAt,
+		DestinationCount: len(destinations),
+		FullFetchAt:      s.FullFetchAt,
+		Destinations:     destinations,
+	})
+}
+
+// Records the changes applied to a shard so the next run can skip reading it.
+// Only the adds Umbrella accepted are cached. If the list still differs, the
+// cached count will not match the list metadata and the next run reads the
+// list in full.
+func updateCache(deps *SyncUmbrellaDependencies, s *shard, lp *ListPlan, added []string) error {
+	removes := make(map[string]bool)
+	for _, d := range lp.Removes {
+		removes[d] = true
+	}
+
+	var destinations []umbrella.Destination
+	present := make(map[string]bool)
+	for _, destination := range s.Destinations {
+		if !removes[destination.Destination] && !present[destination.Destination] {
+			destinations = append(destinations, destination)
+			present[destination.Destination] = true
+		}
+	}
+	for _, d := range added {
+		if !present[d] {
+			destinations = append(destinations, umbrella.Destination{Destination: d})
+			present[d] = true
+		}
+	}
+	s.Destinations = destinations
+
+	fullFetchAt := s.FullFetchAt
+	if fullFetchAt.IsZero() {
+		fullFetchAt = time.Now().UTC()
+	}
+
+	return deps.CacheManager.Save(&cacheManager.ListCache{
+		ListID:           s.List.ID,
+		ModifiedAt:       s.List.ModifiedAt,
+		DestinationCount: len(destinations),
+		FullFetchAt:      fullFetchAt,
+		Destinations:     destinations,
+	})
+}
+
+// Reports whether every destination to remove has a known ID
+func hasIDs(destinations []umbrella.Destination, removes []string) bool {
+	ids := make(map[string]bool)
+	for _, destination := range destinations {
+		if destination.ID != "" {
+			ids[destination.Destination] = true
+		}
+	}
+	for _, d := range removes {
+		if !ids[d] {
+			return false
+		}
+	}
+	return true
+}
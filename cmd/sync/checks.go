@@ -0,0 +1,87 @@
+package sync
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/rules"
+	"github.com/thegrumpyape/umbrellasync/pkg/validation"
+)
+
+// syncChecks holds the settings from config.yaml a sync run applies to every source
+type syncChecks struct {
+	BundleTypes     []bundleType
+	ShardSize       int
+	CacheMaxAge     time.Duration
+	Protected       *validation.Protected
+	FailOnProtected bool
+	Popularity      *popularityCheck
+	Compact         bool
+	Siblings        int
+	Routes          []routeConfig
+	Rules           *rules.Engine
+}
+
+func configuredChecks(deps *SyncUmbrellaDependencies) (*syncChecks, error) {
+	cm := deps.ConfigurationManager
+	checks := &syncChecks{
+		ShardSize:       cm.GetInt("shardsize", defaultShardSize),
+		CacheMaxAge:     time.Duration(cm.GetInt("cachemaxage", defaultCacheMaxAge)) * time.Hour,
+		FailOnProtected: cm.GetBool("failonprotected", false),
+		Compact:         cm.GetBool("compact", false),
+		Siblings:        cm.GetInt("compactsiblings", 0),
+	}
+
+	if checks.ShardSize < 1 || checks.ShardSize > maxShardSize {
+		return nil, fmt.Errorf("shardsize must be between 1 and %d, got %d", maxShardSize, checks.ShardSize)
+	}
+
+	var err error
+	checks.BundleTypes, err = configuredBundleTypes(cm)
+	if err != nil {
+		return nil, err
+	}
+	checks.Protected, err = configuredProtected(cm)
+	if err != nil {
+		return nil, err
+	}
+	checks.Popularity, err = configuredPopularity(cm, deps.Ranking, deps.Logger)
+	if err != nil {
+		return nil, err
+	}
+	checks.Routes, err = configuredRoutes(cm)
+	if err != nil {
+		return nil, err
+	}
+
+	var ruleConfigs []rules.Config
+	err = cm.UnmarshalKey("rules", &ruleConfigs)
+	if err != nil {
+		return nil, fmt.Errorf("Could not get rules from config.yaml: %w", err)
+	}
+	checks.Rules, err = rules.New(ruleConfigs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rule in config.yaml: %w", err)
+	}
+	return checks, nil
+}
+
+// Reports whether a domain sync did not get from a source, such as a parent
+// promoted by compaction, passes every check a source entry has to pass
+func canBlock(deps *SyncUmbrellaDependencies, checks *syncChecks, domain string) (bool, error) {
+	if _, ok := checks.Protected.Match(domain); ok {
+		return false, nil
+	}
+	check, _, err := deps.SuffixList.Check(domain)
+	if err != nil || check == validation.SuffixPublic {
+		return false, err
+	}
+	if popularity := checks.Popularity; popularity != nil {
+		_, rank, ok, err := popularity.Ranking.Rank(domain)
+		if err != nil || (ok && rank <= popularity.Threshold && !popularity.Allowed[domain]) {
+			return false, err
+		}
+	}
+	ignored, err := deps.IgnoreManager.Matches(domain)
+	return !ignored, err
+}
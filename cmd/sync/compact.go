@@ -0,0 +1,118 @@
+package sync
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/umbrella"
+)
+
+const reasonCompacted = "compacted"
+
+// Drops entries that a parent domain in the same list family already blocks,
+// since Umbrella blocks the subdomains of a blocked domain. URLs whose host is
+// blocked as a domain are dropped too. When siblings is set, a parent domain
+// with at least that many subdomains listed replaces them, as long as
+// canPromote allows blocking the parent.
+func compactEntries(entries []string, siblings int, canPromote func(string) (bool, error)) ([]string, []Skipped, error) {
+	domains := make(map[string]bool)
+	for _, entry := range entries {
+		if isCompactableDomain(entry) {
+			domains[entry] = true
+		}
+	}
+
+	promoted := make(map[string]bool)
+	if siblings > 0 {
+		children := make(map[string][]string)
+		for domain := range domains {
+			if parent, ok := parentDomain(domain); ok && !domains[parent] {
+				children[parent] = append(children[parent], domain)
+			}
+		}
+
+		parents := make([]string, 0, len(children))
+		for parent := range children {
+			parents = append(parents, parent)
+		}
+		sort.Strings(parents)
+
+		for _, parent := range parents {
+			if _, covered := coveringDomain(parent, domains); covered || len(children[parent]) < siblings {
+				continue
+			}
+			ok, err := canPromote(parent)
+			if err != nil {
+				return nil, nil, err
+			}
+			if ok {
+				domains[parent] = true
+				promoted[parent] = true
+			}
+		}
+	}
+
+	var compacted []string
+	var collapsed []Skipped
+	for _, entry := range entries {
+		if covering, ok := coveringDomain(entry, domains); ok {
+			detail := "covered by " + covering
+			if promoted[covering] {
+				detail = "promoted to " + covering
+			}
+			collapsed = append(collapsed, Skipped{Value: entry, Reason: reasonCompacted, Detail: detail})
+			continue
+		}
+		compacted = append(compacted, entry)
+	}
+
+	var parents []string
+	for parent := range promoted {
+		if _, covered := coveringDomain(parent, domains); !covered {
+			parents = append(parents, parent)
+		}
+	}
+	sort.Strings(parents)
+	compacted = append(compacted, parents...)
+
+	return compacted, collapsed, nil
+}
+
+// Returns the outermost domain in domains that blocks an entry. A domain is
+// covered by its parents and a URL by its host or the host's parents.
+func coveringDomain(entry string, domains map[string]bool) (string, bool) {
+	covering := ""
+	host := entry
+	if umbrella.IsURL(entry) {
+		host = umbrella.DestinationHost(entry)
+		if !isCompactableDomain(host) {
+			return "", false
+		}
+		if domains[host] {
+			covering = host
+		}
+	} else if !isCompactableDomain(entry) {
+		return "", false
+	}
+
+	for parent, ok := parentDomain(host); ok; parent, ok = parentDomain(parent) {
+		if domains[parent] {
+			covering = parent
+		}
+	}
+	return covering, covering != ""
+}
+
+// Returns the domain one label up, which must still be a dotted domain
+func parentDomain(domain string) (string, bool) {
+	i := strings.Index(domain, ".")
+	if i < 0 || !strings.Contains(domain[i+1:], ".") {
+		return "", false
+	}
+	return domain[i+1:], true
+}
+
+func isCompactableDomain(entry string) bool {
+	return entry != "" && !umbrella.IsURL(entry) && net.ParseIP(entry) == nil && strings.Contains(entry, ".")
+}
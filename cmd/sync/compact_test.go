@@ -0,0 +1,117 @@
+package sync
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompactEntries(t *testing.T) {
+	refused := map[string]bool{"shared.net": true}
+	canPromote := func(parent string) (bool, error) { return !refused[parent], nil }
+
+	tests := []struct {
+		name      string
+		entries   []string
+		siblings  int
+		want      []string
+		collapsed map[string]string
+	}{
+		{
+			name:    "subdomains of a listed domain",
+			entries: []string{"evil.com", "www.evil.com", "a.b.evil.com", "notevil.com"},
+			want:    []string{"evil.com", "notevil.com"},
+			collapsed: map[string]string{
+				"www.evil.com": "covered by evil.com",
+				"a.b.evil.com": "covered by evil.com",
+			},
+		},
+		{
+			name:    "URLs with a scheme",
+			entries: []string{"evil.com", "http://evil.com/login", "https://cdn.evil.com/x?y=1", "http://other.org/path"},
+			want:    []string{"evil.com", "http://other.org/path"},
+			collapsed: map[string]string{
+				"http://evil.com/login":      "covered by evil.com",
+				"https://cdn.evil.com/x?y=1": "covered by evil.com",
+			},
+		},
+		{
+			name:    "URLs without a scheme",
+			entries: []string{"evil.com", "evil.com/login", "www.evil.com/path", "other.org/path"},
+			want:    []string{"evil.com", "other.org/path"},
+			collapsed: map[string]string{
+				"evil.com/login":    "covered by evil.com",
+				"www.evil.com/path": "covered by evil.com",
+			},
+		},
+		{
+			name:    "outermost covering domain",
+			entries: []string{"a.evil.com", "evil.com", "x.a.evil.com"},
+			want:    []string{"evil.com"},
+			collapsed: map[string]string{
+				"a.evil.com":   "covered by evil.com",
+				"x.a.evil.com": "covered by evil.com",
+			},
+		},
+		{
+			name:     "siblings promoted to their parent",
+			entries:  []string{"a.bad.org", "b.bad.org", "c.bad.org", "bad.org/path", "d.other.org"},
+			siblings: 3,
+			want:     []string{"d.other.org", "bad.org"},
+			collapsed: map[string]string{
+				"a.bad.org":    "promoted to bad.org",
+				"b.bad.org":    "promoted to bad.org",
+				"c.bad.org":    "promoted to bad.org",
+				"bad.org/path": "promoted to bad.org",
+			},
+		},
+		{
+			name:     "too few siblings",
+			entries:  []string{"a.bad.org", "b.bad.org"},
+			siblings: 3,
+			want:     []string{"a.bad.org", "b.bad.org"},
+		},
+		{
+			name:     "refused promotion",
+			entries:  []string{"a.shared.net", "b.shared.net", "c.shared.net"},
+			siblings: 3,
+			want:     []string{"a.shared.net", "b.shared.net", "c.shared.net"},
+		},
+		{
+			name:     "no promotion to a top level domain",
+			entries:  []string{"a.com", "b.com", "c.com"},
+			siblings: 2,
+			want:     []string{"a.com", "b.com", "c.com"},
+		},
+		{
+			name:    "addresses are left alone",
+			entries: []string{"192.0.2.1", "http://192.0.2.1/x"},
+			want:    []string{"192.0.2.1", "http://192.0.2.1/x"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, skipped, err := compactEntries(test.entries, test.siblings, canPromote)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("compactEntries() = %v, want %v", got, test.want)
+			}
+
+			collapsed := make(map[string]string)
+			for _, s := range skipped {
+				if s.Reason != reasonCompacted {
+					t.Errorf("%s skipped as %q, want %q", s.Value, s.Reason, reasonCompacted)
+				}
+				collapsed[s.Value] = s.Detail
+			}
+			if test.collapsed == nil {
+				test.collapsed = map[string]string{}
+			}
+			if !reflect.DeepEqual(collapsed, test.collapsed) {
+				t.Errorf("collapsed = %v, want %v", collapsed, test.collapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package sync
+
+import (
+	"time"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/source"
+	"github.com/thegrumpyape/umbrellasync/pkg/stateManager"
+)
+
+// Splits indicators into active and expired ones. An indicator expires at its
+// expires date, or ttl after it was first seen when the source has a ttl.
+// Returns the expiry date of every expired indicator.
+func splitExpired(indicators []source.Indicator, records map[string]stateManager.Record, ttl time.Duration, now time.Time) ([]source.Indicator, map[string]time.Time) {
+	var active []source.Indicator
+	expired := make(map[string]time.Time)
+
+	for _, indicator := range indicators {
+		expires, ok := indicator.Expires()
+		if !ok && ttl > 0 {
+			if record, seen := records[indicator.Value]; seen {
+				expires, ok = record.FirstSeen.Add(ttl), true
+			}
+		}
+
+		if ok && !now.Before(expires) {
+			expired[indicator.Value] = expires
+			continue
+		}
+		active = append(active, indicator)
+	}
+
+	return active, expired
+}
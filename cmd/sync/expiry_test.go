@@ -0,0 +1,89 @@
+package sync
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/source"
+	"github.com/thegrumpyape/umbrellasync/pkg/stateManager"
+)
+
+func TestSplitExpired(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	records := map[string]stateManager.Record{
+		"old.com":   {FirstSeen: now.Add(-10 * day)},
+		"fresh.com": {FirstSeen: now.Add(-day)},
+		"dated.com": {FirstSeen: now.Add(-10 * day)},
+		"edge.com":  {FirstSeen: now.Add(-7 * day)},
+	}
+
+	tests := []struct {
+		name       string
+		indicators []source.Indicator
+		ttl        time.Duration
+		active     string
+		expired    map[string]time.Time
+	}{
+		{
+			name:       "no ttl keeps undated indicators",
+			indicators: []source.Indicator{{Value: "old.com"}, {Value: "unseen.com"}},
+			active:     "old.com,unseen.com",
+		},
+		{
+			name:       "ttl after first seen",
+			indicators: []source.Indicator{{Value: "old.com"}, {Value: "fresh.com"}, {Value: "unseen.com"}},
+			ttl:        7 * day,
+			active:     "fresh.com,unseen.com",
+			expired:    map[string]time.Time{"old.com": now.Add(-3 * day)},
+		},
+		{
+			name:       "expires at the end of the ttl",
+			indicators: []source.Indicator{{Value: "edge.com"}},
+			ttl:        7 * day,
+			expired:    map[string]time.Time{"edge.com": now},
+		},
+		{
+			name: "expires metadata without a ttl",
+			indicators: []source.Indicator{
+				{Value: "past.com", Metadata: map[string]string{"expires": "2026-05-01"}},
+				{Value: "future.com", Metadata: map[string]string{"expires": "2026-06-01T13:00:00Z"}},
+			},
+			active:  "future.com",
+			expired: map[string]time.Time{"past.com": time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name: "expires metadata wins over the ttl",
+			indicators: []source.Indicator{
+				{Value: "dated.com", Metadata: map[string]string{"expires": "2026-12-01"}},
+				{Value: "fresh.com", Metadata: map[string]string{"expires": "2026-05-01"}},
+			},
+			ttl:     7 * day,
+			active:  "dated.com",
+			expired: map[string]time.Time{"fresh.com": time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:       "invalid expires metadata falls back to the ttl",
+			indicators: []source.Indicator{{Value: "old.com", Metadata: map[string]string{"expires": "soon"}}},
+			ttl:        7 * day,
+			expired:    map[string]time.Time{"old.com": now.Add(-3 * day)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			active, expired := splitExpired(test.indicators, records, test.ttl, now)
+			if got := strings.Join(source.Values(active), ","); got != test.active {
+				t.Errorf("active = %q, want %q", got, test.active)
+			}
+			if test.expired == nil {
+				test.expired = map[string]time.Time{}
+			}
+			if !reflect.DeepEqual(expired, test.expired) {
+				t.Errorf("expired = %v, want %v", expired, test.expired)
+			}
+		})
+	}
+}
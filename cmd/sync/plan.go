@@ -0,0 +1,200 @@
+package sync
+
+import (
+	"sort"
+	"time"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/logging"
+	"github.com/thegrumpyape/umbrellasync/pkg/source"
+	"github.com/thegrumpyape/umbrellasync/pkg/umbrella"
+)
+
+// ListPlan holds the changes sync will make to a single destination list
+type ListPlan struct {
+	Name    string
+	Shard   int
+	ListID  int
+	Adds    []string
+	Removes []string
+}
+
+// Plan holds the changes sync will make for a single source to the lists of one bundle type
+type Plan struct {
+	Source     string
+	BundleType bundleType
+	Route      string
+	Lists      []*ListPlan
+	Indicators []source.Indicator
+	Expired    map[string]time.Time
+	Compacted  []Skipped
+}
+
+// Returns the destinations to add to a list, commented with the metadata of their indicators
+func (p *Plan) NewDestinations(lp *ListPlan) []umbrella.NewDestination {
+	comments := make(map[string]string)
+	for _, indicator := range p.Indicators {
+		comments[indicator.Value] = indicator.Comment()
+	}
+
+	destinations := make([]umbrella.NewDestination, len(lp.Adds))
+	for i, d := range lp.Adds {
+		destinations[i] = umbrella.NewDestination{Destination: d, Comment: comments[d]}
+	}
+	return destinations
+}
+
+func (p *Plan) HasChanges() bool {
+	for _, lp := range p.Lists {
+		if lp.ListID == 0 || len(lp.Adds) > 0 || len(lp.Removes) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Logs a summary for every list. When detailed, every entry is logged with
+// the shard it belongs to, otherwise entries are only logged at debug level.
+func (p *Plan) Log(logger logging.Logger, detailed bool) {
+	entry := logger.Debug
+	if detailed {
+		entry = logger.Info
+	}
+
+	route := ""
+	if p.Route != "" {
+		route = ", routes " + p.Route
+	}
+	logger.Info("Plan for ", p.Source, " (", p.BundleType.Name, " lists", route, ")")
+	for _, lp := range p.Lists {
+		status := ""
+		if lp.ListID == 0 {
+			status = " (new)"
+		}
+		expired := 0
+		for _, d := range lp.Removes {
+			if _, ok := p.Expired[d]; ok {
+				expired++
+			}
+		}
+		logger.Info("  ", lp.Name, status, ": +", len(lp.Adds), " -", len(lp.Removes), " (", expired, " expired)")
+
+		sort.Strings(lp.Adds)
+		sort.Strings(lp.Removes)
+		for _, d := range lp.Adds {
+			entry("    + ", d, " [shard #", lp.Shard, "]")
+		}
+		for _, d := range lp.Removes {
+			if expires, ok := p.Expired[d]; ok {
+				entry("    x ", d, " [expired ", expires.Format("2006-01-02"), ", shard #", lp.Shard, "]")
+				continue
+			}
+			entry("    - ", d, " [shard #", lp.Shard, "]")
+		}
+	}
+
+	if len(p.Compacted) != 0 {
+		logger.Info("  compacted: ", len(p.Compacted))
+		for _, c := range p.Compacted {
+			entry("    ~ ", c.Value, " [", c.Detail, "]")
+		}
+	}
+}
+
+// Skipped is a source entry that was left out of every list
+type Skipped struct {
+	Value  string
+	Reason string
+	Detail string
+}
+
+// Logs the entries of a source that were left out, grouped by reason. When
+// detailed, every entry is logged, otherwise only at debug level.
+func logSkipped(logger logging.Logger, sourceName string, skipped []Skipped, detailed bool) {
+	if len(skipped) == 0 {
+		return
+	}
+	entry := logger.Debug
+	if detailed {
+		entry = logger.Info
+	}
+
+	byReason := make(map[string][]Skipped)
+	var reasons []string
+	for _, s := range skipped {
+		if _, ok := byReason[s.Reason]; !ok {
+			reasons = append(reasons, s.Reason)
+		}
+		byReason[s.Reason] = append(byReason[s.Reason], s)
+	}
+
+	logger.Info("Skipped from ", sourceName, ":")
+	for _, reason := range reasons {
+		logger.Info("  ", reason, ": ", len(byReason[reason]))
+		for _, s := range byReason[reason] {
+			entry("    ~ ", s.Value, " [", s.Detail, "]")
+		}
+	}
+}
+
+// Summary totals the plans and skipped entries of every source in a run
+type Summary struct {
+	Sources int
+	Added   int
+	Removed int
+	Expired int
+	Skipped map[string]int
+	Held    []Skipped
+}
+
+func (s *Summary) AddPlan(p *Plan) {
+	for _, lp := range p.Lists {
+		s.Added += len(lp.Adds)
+		s.Removed += len(lp.Removes)
+		for _, d := range lp.Removes {
+			if _, ok := p.Expired[d]; ok {
+				s.Expired++
+			}
+		}
+	}
+	s.AddSkipped(p.Source, p.Compacted)
+}
+
+// Counts the skipped entries of a source. Held entries are kept so they can
+// be listed for review.
+func (s *Summary) AddSkipped(sourceName string, skipped []Skipped) {
+	if s.Skipped == nil {
+		s.Skipped = make(map[string]int)
+	}
+	for _, entry := range skipped {
+		if entry.Reason == reasonHeld {
+			entry.Detail = sourceName + ", " + entry.Detail
+			s.Held = append(s.Held, entry)
+			continue
+		}
+		s.Skipped[entry.Reason]++
+	}
+}
+
+func (s *Summary) Log(logger logging.Logger, dryRun bool) {
+	verb := "Synced"
+	if dryRun {
+		verb = "Planned"
+	}
+	logger.Info(verb, " ", s.Sources, " sources: +", s.Added, " -", s.Removed, " (", s.Expired, " expired)")
+
+	reasons := make([]string, 0, len(s.Skipped))
+	for reason := range s.Skipped {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		logger.Info("  skipped as ", reason, ": ", s.Skipped[reason])
+	}
+
+	if len(s.Held) != 0 {
+		logger.Warn("  held for review: ", len(s.Held))
+		for _, entry := range s.Held {
+			logger.Warn("    ? ", entry.Value, " [", entry.Detail, "]")
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/configurationManager"
+	"github.com/thegrumpyape/umbrellasync/pkg/logging"
+	"github.com/thegrumpyape/umbrellasync/pkg/source"
+	"github.com/thegrumpyape/umbrellasync/pkg/validation"
+)
+
+const reasonHeld = "held for review"
+
+// popularityCheck holds indicators ranked at or above Threshold in the top
+// sites ranking. Domains in Allowed have been reviewed and are synced anyway.
+type popularityCheck struct {
+	Ranking   *validation.Ranking
+	Threshold int
+	Allowed   map[string]bool
+}
+
+// Reads the popularity threshold and reviewed domains from config.yaml. The
+// check is disabled without a threshold or an imported ranking.
+func configuredPopularity(cm *configurationManager.ConfigurationManager, ranking *validation.Ranking, logger logging.Logger) (*popularityCheck, error) {
+	threshold := cm.GetInt("popularitythreshold", 0)
+	if threshold <= 0 {
+		return nil, nil
+	}
+
+	available, err := ranking.Available()
+	if err != nil {
+		return nil, err
+	}
+	if !available {
+		logger.Warn("popularitythreshold is set but no top sites ranking has been imported, run umbrellasync toplist import")
+		return nil, nil
+	}
+
+	allowed, err := cm.GetStringList("popularityallow")
+	if err != nil {
+		return nil, err
+	}
+	check := &popularityCheck{Ranking: ranking, Threshold: threshold, Allowed: make(map[string]bool)}
+	for _, domain := range allowed {
+		// Matched against indicators, which are lowercased without a trailing dot
+		domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain != "" {
+			check.Allowed[domain] = true
+		}
+	}
+	return check, nil
+}
+
+// Holds back indicators for popular domains
+func holdPopular(logger logging.Logger, check *popularityCheck, sourceName string, indicators []source.Indicator) ([]source.Indicator, []Skipped, error) {
+	if check == nil {
+		return indicators, nil, nil
+	}
+
+	var kept []source.Indicator
+	var held []Skipped
+	for _, indicator := range indicators {
+		domain, rank, ok, err := check.Ranking.Rank(indicator.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok || rank > check.Threshold || check.Allowed[indicator.Value] || check.Allowed[domain] {
+			kept = append(kept, indicator)
+			continue
+		}
+		logger.Warn(indicator.Value, " in ", sourceName, " held for review, ", domain, " is ranked ", rank)
+		held = append(held, Skipped{Value: indicator.Value, Reason: reasonHeld, Detail: fmt.Sprintf("%s ranked %d", domain, rank)})
+	}
+	return kept, held, nil
+}
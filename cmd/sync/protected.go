@@ -0,0 +1,150 @@
+package sync
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/configurationManager"
+	"github.com/thegrumpyape/umbrellasync/pkg/ignoreManager"
+	"github.com/thegrumpyape/umbrellasync/pkg/logging"
+	"github.com/thegrumpyape/umbrellasync/pkg/source"
+	"github.com/thegrumpyape/umbrellasync/pkg/umbrella"
+	"github.com/thegrumpyape/umbrellasync/pkg/validation"
+)
+
+const (
+	reasonProtected    = "protected"
+	reasonPublicSuffix = "public suffix"
+	reasonIgnored      = "ignored"
+	reasonAddress      = "IP address"
+	reasonInvalid      = "invalid"
+)
+
+// Reads the protected domains and CIDRs from config.yaml
+func configuredProtected(cm *configurationManager.ConfigurationManager) (*validation.Protected, error) {
+	domains, err := cm.GetStringList("protecteddomains")
+	if err != nil {
+		return nil, err
+	}
+	cidrs, err := cm.GetStringList("protectedcidrs")
+	if err != nil {
+		return nil, err
+	}
+	return validation.NewProtected(domains, cidrs)
+}
+
+// Drops the indicators that would block a protected domain or network. Every
+// suppressed indicator is logged as a warning.
+func suppressProtected(logger logging.Logger, protected *validation.Protected, sourceName string, indicators []source.Indicator) ([]source.Indicator, []Skipped) {
+	var kept []source.Indicator
+	var skipped []Skipped
+	for _, indicator := range indicators {
+		rule, ok := protected.Match(indicator.Value)
+		if !ok {
+			kept = append(kept, indicator)
+			continue
+		}
+		logger.Warn(sourceName, " tried to block protected ", indicator.Value, " (matches ", rule, ")")
+		skipped = append(skipped, Skipped{Value: indicator.Value, Reason: reasonProtected, Detail: "matches " + rule})
+	}
+	return kept, skipped
+}
+
+func protectedError(sourceName string, skipped []Skipped) error {
+	count := 0
+	for _, s := range skipped {
+		if s.Reason == reasonProtected {
+			count++
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s tried to block %d protected destinations, failing because failonprotected is set", sourceName, count)
+}
+
+// Drops domains that are public suffixes and warns about tenants of shared
+// hosting suffixes, which block every host the tenant runs
+func suppressPublicSuffixes(logger logging.Logger, suffixList *validation.SuffixList, sourceName string, indicators []source.Indicator) ([]source.Indicator, []Skipped, error) {
+	var kept []source.Indicator
+	var skipped []Skipped
+	for _, indicator := range indicators {
+		check, suffix, err := suffixList.Check(indicator.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch check {
+		case validation.SuffixPublic:
+			logger.Warn(sourceName, " tried to block public suffix ", indicator.Value)
+			skipped = append(skipped, Skipped{Value: indicator.Value, Reason: reasonPublicSuffix, Detail: "public suffix"})
+			continue
+		case validation.SuffixSharedTenant:
+			logger.Warn(indicator.Value, " in ", sourceName, " is a tenant of shared hosting suffix ", suffix, ", every host under it will be blocked")
+		}
+		kept = append(kept, indicator)
+	}
+	return kept, skipped, nil
+}
+
+// Drops the indicators whose host is on the ignore list, which Umbrella
+// would reject or the connector would drop when adding them
+func suppressIgnored(ignores *ignoreManager.IgnoreManager, indicators []source.Indicator) ([]source.Indicator, []Skipped, error) {
+	var kept []source.Indicator
+	var skipped []Skipped
+	for _, indicator := range indicators {
+		ignored, err := ignores.Matches(umbrella.DestinationHost(indicator.Value))
+		if err != nil {
+			return nil, nil, err
+		}
+		if ignored {
+			skipped = append(skipped, Skipped{Value: indicator.Value, Reason: reasonIgnored, Detail: "on the ignore list"})
+			continue
+		}
+		kept = append(kept, indicator)
+	}
+	return kept, skipped, nil
+}
+
+// Drops IP addresses and URLs on an IP address. Sources like STIX, MISP and
+// extracted reports carry them, but destination lists only take domains and
+// URLs on a domain.
+func suppressAddresses(indicators []source.Indicator) ([]source.Indicator, []Skipped) {
+	var kept []source.Indicator
+	var skipped []Skipped
+	for _, indicator := range indicators {
+		host := umbrella.DestinationHost(indicator.Value)
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if net.ParseIP(strings.Trim(host, "[]")) != nil {
+			skipped = append(skipped, Skipped{Value: indicator.Value, Reason: reasonAddress, Detail: "destination lists do not accept IP addresses"})
+			continue
+		}
+		kept = append(kept, indicator)
+	}
+	return kept, skipped
+}
+
+// Rewrites the indicators as the connector sends them, so the plan compares
+// the values lists actually hold. Indicators without a host are dropped and
+// ones that end up equal to an earlier indicator are merged into it.
+func normalizeDestinations(indicators []source.Indicator) ([]source.Indicator, []Skipped) {
+	var kept []source.Indicator
+	var skipped []Skipped
+	seen := make(map[string]bool)
+	for _, indicator := range indicators {
+		normalized, ok := umbrella.NormalizeDestination(indicator.Value)
+		if !ok {
+			skipped = append(skipped, Skipped{Value: indicator.Value, Reason: reasonInvalid, Detail: "not a domain or URL"})
+			continue
+		}
+		if seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		indicator.Value = normalized
+		kept = append(kept, indicator)
+	}
+	return kept, skipped
+}
@@ -0,0 +1,119 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/configurationManager"
+	"github.com/thegrumpyape/umbrellasync/pkg/source"
+)
+
+const (
+	reasonDropped = "dropped by route"
+	defaultRoute  = "unmatched"
+)
+
+// routeConfig sends the indicators matching all of its expressions and any of
+// its labels to a list family, or drops them. The first matching route wins.
+type routeConfig struct {
+	Name   string   `mapstructure:"name"`
+	Match  []string `mapstructure:"match"`
+	Labels []string `mapstructure:"labels"`
+	List   string   `mapstructure:"list"`
+	Drop   bool     `mapstructure:"drop"`
+	filter source.Filter
+}
+
+// routeGroup holds the indicators routed to one list family of a unit
+type routeGroup struct {
+	Routes     []string
+	List       string
+	Indicators []source.Indicator
+}
+
+// Reads the routes from config.yaml. A route without a name is named after its list.
+func configuredRoutes(cm *configurationManager.ConfigurationManager) ([]routeConfig, error) {
+	var routes []routeConfig
+	err := cm.UnmarshalKey("routes", &routes)
+	if err != nil {
+		return nil, fmt.Errorf("Could not get routes from config.yaml: %w", err)
+	}
+
+	for i := range routes {
+		route := &routes[i]
+		route.filter, err = source.ParseFilter(route.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route %d: %w", i+1, err)
+		}
+		if route.Name == "" {
+			route.Name = route.List
+		}
+		if route.Name == "" {
+			route.Name = fmt.Sprintf("route %d", i+1)
+		}
+	}
+	return routes, nil
+}
+
+func (r routeConfig) matches(indicator source.Indicator) bool {
+	if len(r.Labels) > 0 && !indicator.HasLabel(r.Labels) {
+		return false
+	}
+	return r.filter.Match(indicator)
+}
+
+// Splits indicators into list families by the first matching route.
+// Indicators no route matches go to the default family. Every family a route
+// can reach is returned, even when empty, so entries are removed from lists
+// once no indicator is routed there any more.
+func routeIndicators(routes []routeConfig, indicators []source.Indicator) ([]*routeGroup, []Skipped) {
+	groups := []*routeGroup{{}}
+	byList := map[string]*routeGroup{"": groups[0]}
+	for _, route := range routes {
+		if route.Drop {
+			continue
+		}
+		group, ok := byList[route.List]
+		if !ok {
+			group = &routeGroup{List: route.List}
+			byList[route.List] = group
+			groups = append(groups, group)
+		}
+		group.Routes = append(group.Routes, route.Name)
+	}
+	groups[0].Routes = append(groups[0].Routes, defaultRoute)
+
+	var dropped []Skipped
+	for _, indicator := range indicators {
+		group := byList[""]
+		for _, route := range routes {
+			if !route.matches(indicator) {
+				continue
+			}
+			if route.Drop {
+				group = nil
+				dropped = append(dropped, Skipped{Value: indicator.Value, Reason: reasonDropped, Detail: route.Name})
+			} else {
+				group = byList[route.List]
+			}
+			break
+		}
+		if group != nil {
+			group.Indicators = append(group.Indicators, indicator)
+		}
+	}
+	return groups, dropped
+}
+
+// Returns the routes feeding the list family
+func (g *routeGroup) String() string {
+	return strings.Join(g.Routes, ", ")
+}
+
+// Returns the base name of a list family
+func (g *routeGroup) base(bt bundleType, unitName string) string {
+	if g.List == "" {
+		return bt.Prefix + unitName
+	}
+	return bt.Prefix + unitName + " " + g.List
+}
@@ -0,0 +1,37 @@
+package sync
+
+import (
+	"github.com/thegrumpyape/umbrellasync/pkg/rules"
+	"github.com/thegrumpyape/umbrellasync/pkg/source"
+)
+
+const reasonRule = "dropped by rule"
+
+// Applies the transform and filter rules to the indicators of a source. Every
+// change is logged, and the trace is kept in the state so explain can show
+// which rule changed or dropped an entry.
+func applyRules(deps *SyncUmbrellaDependencies, engine *rules.Engine, sourceName string, indicators []source.Indicator) ([]source.Indicator, []Skipped, error) {
+	indicators, traces := engine.Apply(sourceName, indicators)
+
+	entry := deps.Logger.Debug
+	if deps.DryRun {
+		entry = deps.Logger.Info
+	}
+
+	var dropped []Skipped
+	changed := 0
+	for _, trace := range traces {
+		if trace.Dropped() {
+			dropped = append(dropped, Skipped{Value: trace.Original, Reason: reasonRule, Detail: trace.Rule})
+			continue
+		}
+		changed++
+		entry("  ", trace.Original, " -> ", trace.Result, " [", trace.Rule, "]")
+	}
+	if changed != 0 {
+		deps.Logger.Info(changed, " entries of ", sourceName, " rewritten by rules")
+	}
+
+	err := deps.StateManager.RecordTraces(sourceName, traces, !deps.DryRun)
+	return indicators, dropped, err
+}
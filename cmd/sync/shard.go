@@ -0,0 +1,145 @@
+package sync
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/umbrella"
+)
+
+// Umbrella caps the number of destinations in a single list
+const (
+	maxShardSize     = 100000
+	defaultShardSize = maxShardSize
+)
+
+type shard struct {
+	Index        int
+	Name         string
+	List         umbrella.DestinationList
+	Destinations []umbrella.Destination
+	FullFetchAt  time.Time
+}
+
+func shardName(base string, index int) string {
+	return fmt.Sprintf("%s #%d", base, index)
+}
+
+var numberedList = regexp.MustCompile(` #\d+$`)
+
+// Finds the numbered family of lists for base. A list named exactly base, or
+// containing the source file name as lists did before sharding, is shard #1.
+// Numbered lists of other families are never taken as legacy lists.
+func findShards(destinationLists []umbrella.DestinationList, base string, legacyName string) []*shard {
+	numbered := regexp.MustCompile(`^` + regexp.QuoteMeta(base) + ` #(\d+)$`)
+	shardMap := make(map[int]*shard)
+	var legacy *umbrella.DestinationList
+
+	for i, dl := range destinationLists {
+		if match := numbered.FindStringSubmatch(dl.Name); match != nil {
+			index, _ := strconv.Atoi(match[1])
+			if _, ok := shardMap[index]; !ok && index > 0 {
+				shardMap[index] = &shard{Index: index, Name: dl.Name, List: dl}
+			}
+			continue
+		}
+		if legacy == nil && !numberedList.MatchString(dl.Name) && (dl.Name == base || (legacyName != "" && strings.Contains(dl.Name, legacyName))) {
+			legacy = &destinationLists[i]
+		}
+	}
+
+	if _, ok := shardMap[1]; !ok && legacy != nil {
+		shardMap[1] = &shard{Index: 1, Name: legacy.Name, List: *legacy}
+	}
+
+	var shards []*shard
+	for _, s := range shardMap {
+		shards = append(shards, s)
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i].Index < shards[j].Index })
+	return shards
+}
+
+// Assigns the desired destinations to shards. Destinations already in a shard
+// stay there, so entries only move when a shard is over capacity. New entries
+// fill shards in order and new shards are appended when all are full.
+func assignShards(shards []*shard, base string, desired []string, capacity int) ([]*shard, map[int][]string) {
+	desiredMap := make(map[string]bool)
+	for _, d := range desired {
+		desiredMap[d] = true
+	}
+
+	membership := make(map[int][]string)
+	placed := make(map[string]bool)
+	var pending []string
+
+	for _, s := range shards {
+		var kept []string
+		for _, destination := range s.Destinations {
+			d := destination.Destination
+			if !desiredMap[d] || placed[d] {
+				continue
+			}
+			kept = append(kept, d)
+			placed[d] = true
+		}
+
+		if len(kept) > capacity {
+			sortByHash(kept)
+			pending = append(pending, kept[capacity:]...)
+			kept = kept[:capacity]
+		}
+		membership[s.Index] = kept
+	}
+
+	for d := range desiredMap {
+		if !placed[d] {
+			pending = append(pending, d)
+		}
+	}
+	sortByHash(pending)
+
+	next := 0
+	for len(pending) > 0 {
+		if next == len(shards) {
+			index := 1
+			if len(shards) > 0 {
+				index = shards[len(shards)-1].Index + 1
+			}
+			shards = append(shards, &shard{Index: index, Name: shardName(base, index)})
+		}
+
+		s := shards[next]
+		room := capacity - len(membership[s.Index])
+		if room > len(pending) {
+			room = len(pending)
+		}
+		if room > 0 {
+			membership[s.Index] = append(membership[s.Index], pending[:room]...)
+			pending = pending[room:]
+		}
+		next++
+	}
+
+	return shards, membership
+}
+
+func sortByHash(values []string) {
+	hash := func(s string) uint64 {
+		h := fnv.New64a()
+		h.Write([]byte(s))
+		return h.Sum64()
+	}
+	sort.Slice(values, func(i, j int) bool {
+		hi, hj := hash(values[i]), hash(values[j])
+		if hi != hj {
+			return hi < hj
+		}
+		return values[i] < values[j]
+	})
+}
@@ -0,0 +1,206 @@
+package sync
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/umbrella"
+)
+
+func TestFindShards(t *testing.T) {
+	tests := []struct {
+		name       string
+		lists      []string
+		legacyName string
+		want       []string
+	}{
+		{name: "none", lists: []string{"other #1"}, want: nil},
+		{name: "numbered family in order", lists: []string{"feed #2", "other #1", "feed #1"}, want: []string{"feed #1", "feed #2"}},
+		{name: "gaps are kept", lists: []string{"feed #3", "feed #1"}, want: []string{"feed #1", "feed #3"}},
+		{name: "index zero is not a shard", lists: []string{"feed #0"}, want: nil},
+		{name: "legacy list named like the family", lists: []string{"feed"}, want: []string{"feed"}},
+		{name: "legacy list named after the file", lists: []string{"Block osint.txt"}, legacyName: "osint.txt", want: []string{"Block osint.txt"}},
+		{name: "numbered #1 wins over a legacy list", lists: []string{"feed", "feed #1"}, want: []string{"feed #1"}},
+		{name: "legacy list next to later shards", lists: []string{"feed #2", "feed"}, want: []string{"feed", "feed #2"}},
+		{name: "another family is not adopted", lists: []string{"osint.txt #1", "feed extra #2"}, legacyName: "osint.txt", want: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var lists []umbrella.DestinationList
+			for i, name := range test.lists {
+				lists = append(lists, umbrella.DestinationList{ID: i + 1, Name: name})
+			}
+
+			var got []string
+			for _, s := range findShards(lists, "feed", test.legacyName) {
+				if s.List.Name != s.Name {
+					t.Errorf("shard %d is named %q but holds list %q", s.Index, s.Name, s.List.Name)
+				}
+				if !numberedList.MatchString(s.Name) && s.Index != 1 {
+					t.Errorf("legacy list %q is shard %d, want 1", s.Name, s.Index)
+				}
+				got = append(got, s.Name)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("findShards() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAssignShards(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing [][]string
+		desired  []string
+		capacity int
+		want     []int
+		stay     map[string]int
+	}{
+		{
+			name:     "new shards are created",
+			desired:  []string{"a.com", "b.com", "c.com", "d.com", "e.com"},
+			capacity: 2,
+			want:     []int{2, 2, 1},
+		},
+		{
+			name:     "entries stay in their shard",
+			existing: [][]string{{"a.com", "b.com"}, {"c.com", "d.com"}},
+			desired:  []string{"d.com", "c.com", "b.com", "a.com"},
+			capacity: 2,
+			want:     []int{2, 2},
+			stay:     map[string]int{"a.com": 1, "b.com": 1, "c.com": 2, "d.com": 2},
+		},
+		{
+			name:     "removed entries free room for new ones",
+			existing: [][]string{{"a.com", "b.com"}, {"c.com"}},
+			desired:  []string{"a.com", "c.com", "e.com", "f.com"},
+			capacity: 2,
+			want:     []int{2, 2},
+			stay:     map[string]int{"a.com": 1, "c.com": 2},
+		},
+		{
+			name:     "over capacity spills into the next shard",
+			existing: [][]string{{"a.com", "b.com", "c.com", "d.com"}, {"e.com"}},
+			desired:  []string{"a.com", "b.com", "c.com", "d.com", "e.com"},
+			capacity: 3,
+			want:     []int{3, 2},
+			stay:     map[string]int{"e.com": 2},
+		},
+		{
+			name:     "over capacity spills into a new shard",
+			existing: [][]string{{"a.com", "b.com", "c.com"}},
+			desired:  []string{"a.com", "b.com", "c.com"},
+			capacity: 2,
+			want:     []int{2, 1},
+		},
+		{
+			name:     "duplicate across shards is kept once",
+			existing: [][]string{{"a.com"}, {"a.com", "b.com"}},
+			desired:  []string{"a.com", "b.com"},
+			capacity: 2,
+			want:     []int{1, 1},
+			stay:     map[string]int{"a.com": 1, "b.com": 2},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shards := shardsOf(test.existing)
+			shards, membership := assignShards(shards, "feed", test.desired, test.capacity)
+			checkMembership(t, shards, membership, test.desired, test.capacity)
+
+			var sizes []int
+			for _, s := range shards {
+				sizes = append(sizes, len(membership[s.Index]))
+				if s.Name != shardName("feed", s.Index) {
+					t.Errorf("shard %d is named %q", s.Index, s.Name)
+				}
+			}
+			if !reflect.DeepEqual(sizes, test.want) {
+				t.Errorf("shard sizes = %v, want %v", sizes, test.want)
+			}
+			for value, index := range test.stay {
+				if !contains(membership[index], value) {
+					t.Errorf("%s moved out of shard %d: %v", value, index, membership)
+				}
+			}
+
+			// A second run over the first run's lists changes nothing
+			again, second := assignShards(shardsOfMembership(shards, membership), "feed", test.desired, test.capacity)
+			if len(again) != len(shards) {
+				t.Fatalf("second run has %d shards, want %d", len(again), len(shards))
+			}
+			for _, s := range shards {
+				if !sameValues(second[s.Index], membership[s.Index]) {
+					t.Errorf("second run moved shard %d from %v to %v", s.Index, membership[s.Index], second[s.Index])
+				}
+			}
+		})
+	}
+}
+
+// Builds the numbered shards of the feed family holding existing
+func shardsOf(existing [][]string) []*shard {
+	var shards []*shard
+	for i, values := range existing {
+		s := &shard{Index: i + 1, Name: shardName("feed", i+1)}
+		for _, value := range values {
+			s.Destinations = append(s.Destinations, umbrella.Destination{Destination: value})
+		}
+		shards = append(shards, s)
+	}
+	return shards
+}
+
+func shardsOfMembership(shards []*shard, membership map[int][]string) []*shard {
+	var next []*shard
+	for _, s := range shards {
+		n := &shard{Index: s.Index, Name: s.Name}
+		for _, value := range membership[s.Index] {
+			n.Destinations = append(n.Destinations, umbrella.Destination{Destination: value})
+		}
+		next = append(next, n)
+	}
+	return next
+}
+
+// Checks every desired value is placed exactly once and no shard is over capacity
+func checkMembership(t *testing.T, shards []*shard, membership map[int][]string, desired []string, capacity int) {
+	t.Helper()
+	placed := make(map[string]int)
+	for _, s := range shards {
+		if len(membership[s.Index]) > capacity {
+			t.Errorf("shard %d holds %d entries, over the capacity of %d", s.Index, len(membership[s.Index]), capacity)
+		}
+		for _, value := range membership[s.Index] {
+			placed[value]++
+		}
+	}
+	for _, value := range desired {
+		if placed[value] != 1 {
+			t.Errorf("%s is placed %d times, want once", value, placed[value])
+		}
+	}
+	if len(placed) != len(desired) {
+		t.Errorf("placed %d values, want %d", len(placed), len(desired))
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func sameValues(a, b []string) bool {
+	a, b = append([]string(nil), a...), append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	return reflect.DeepEqual(a, b)
+}
@@ -3,27 +3,49 @@ package sync
 import (
 	"fmt"
 	"log"
-	"strings"
+	"sort"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/thegrumpyape/umbrellasync/pkg/cacheManager"
 	"github.com/thegrumpyape/umbrellasync/pkg/configurationManager"
-	"github.com/thegrumpyape/umbrellasync/pkg/fileManager"
+	"github.com/thegrumpyape/umbrellasync/pkg/ignoreManager"
 	"github.com/thegrumpyape/umbrellasync/pkg/logging"
+	"github.com/thegrumpyape/umbrellasync/pkg/source"
+	"github.com/thegrumpyape/umbrellasync/pkg/stateManager"
 	"github.com/thegrumpyape/umbrellasync/pkg/umbrella"
+	"github.com/thegrumpyape/umbrellasync/pkg/validation"
 )
 
 type SyncCommandDependencies struct {
 	ConfigurationManager *configurationManager.ConfigurationManager
+	IgnoreManager        *ignoreManager.IgnoreManager
+	CacheManager         *cacheManager.CacheManager
+	SourceLoader         *source.Loader
+	StateManager         *stateManager.StateManager
+	SuffixList           *validation.SuffixList
+	Ranking              *validation.Ranking
 	Logger               logging.Logger
 }
 
 type SyncUmbrellaDependencies struct {
 	ConfigurationManager *configurationManager.ConfigurationManager
 	UmbrellaConnector    *umbrella.UmbrellaConnector
+	IgnoreManager        *ignoreManager.IgnoreManager
+	CacheManager         *cacheManager.CacheManager
+	SourceLoader         *source.Loader
+	StateManager         *stateManager.StateManager
+	SuffixList           *validation.SuffixList
+	Ranking              *validation.Ranking
 	Logger               logging.Logger
+	DryRun               bool
+	Refresh              bool
 }
 
 func New(deps *SyncCommandDependencies) *cobra.Command {
+	var dryRun bool
+	var refresh bool
+
 	syncCmd := &cobra.Command{
 		Use:   "sync",
 		Short: "Sync threat intel with Umbrella",
@@ -32,8 +54,13 @@ func New(deps *SyncCommandDependencies) *cobra.Command {
 
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			err := migrateHighVolumeDomains(deps)
+			if err != nil {
+				return err
+			}
+
 			umbrellaClient := umbrella.CreateUmbrellaClient(*deps.ConfigurationManager, deps.Logger)
-			umbrellaConnector, err := umbrella.New(umbrellaClient, *deps.ConfigurationManager, deps.Logger)
+			umbrellaConnector, err := umbrella.New(umbrellaClient, *deps.ConfigurationManager, deps.IgnoreManager, deps.Logger)
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -41,7 +68,15 @@ func New(deps *SyncCommandDependencies) *cobra.Command {
 			syncUmbrellaDeps := &SyncUmbrellaDependencies{
 				ConfigurationManager: deps.ConfigurationManager,
 				UmbrellaConnector:    umbrellaConnector,
+				IgnoreManager:        deps.IgnoreManager,
+				CacheManager:         deps.CacheManager,
+				SourceLoader:         deps.SourceLoader,
+				StateManager:         deps.StateManager,
+				SuffixList:           deps.SuffixList,
+				Ranking:              deps.Ranking,
 				Logger:               deps.Logger,
+				DryRun:               dryRun,
+				Refresh:              refresh,
 			}
 
 			return executeSync(syncUmbrellaDeps)
@@ -51,22 +86,52 @@ func New(deps *SyncCommandDependencies) *cobra.Command {
 		},
 	}
 
+	syncCmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the plan without changing Umbrella")
+	syncCmd.Flags().BoolVar(&refresh, "refresh", false, "ignore the local list cache and read every destination from Umbrella")
+
 	return syncCmd
 }
 
-func executeSync(deps *SyncUmbrellaDependencies) error {
-	values, ok := deps.ConfigurationManager.Get("files").([]interface{})
-	if !ok {
-		return fmt.Errorf("Could not get files from config.yaml")
+func executeSync(deps *SyncUmbrellaDependencies) (err error) {
+	// The state is written once, including what was observed before a failure
+	defer func() {
+		flushErr := deps.StateManager.Flush()
+		if err == nil {
+			err = flushErr
+		}
+	}()
+
+	sourceConfigs, err := loadSourceConfigs(deps.ConfigurationManager)
+	if err != nil {
+		return err
+	}
+	targets, err := loadTargets(deps.ConfigurationManager, sourceConfigs)
+	if err != nil {
+		return err
+	}
+
+	checks, err := configuredChecks(deps)
+	if err != nil {
+		return err
 	}
 
-	filepaths := make([]string, len(values))
-	for i, v := range values {
-		str, ok := v.(string)
-		if !ok {
-			return fmt.Errorf("Element at index %d is not a string", i)
+	summary := &Summary{}
+
+	// Every source is read and validated before anything changes in Umbrella
+	var results []*sourceResult
+	for _, sourceConfig := range sourceConfigs {
+		result, skipped, err := readSource(deps, checks, sourceConfig)
+		if err != nil {
+			return err
 		}
-		filepaths[i] = str
+		summary.Sources++
+		summary.AddSkipped(sourceConfig.Name, skipped)
+		results = append(results, result)
+	}
+
+	units, err := buildUnits(targets, results)
+	if err != nil {
+		return err
 	}
 
 	destinationLists, err := deps.UmbrellaConnector.GetDestinationLists(100)
@@ -74,100 +139,361 @@ func executeSync(deps *SyncUmbrellaDependencies) error {
 		return err
 	}
 
-	for _, filepath := range filepaths {
-		fileInfo, err := fileManager.FileInfo(filepath)
+	for _, unit := range units {
+		groups, dropped := routeIndicators(checks.Routes, unit.Indicators)
+		skipped := append(unit.Pending, dropped...)
+		logSkipped(deps.Logger, unit.Name, skipped, deps.DryRun)
+		summary.AddSkipped(unit.Name, skipped)
+
+		if unit.Target {
+			err = deps.StateManager.ObserveTarget(unit.Name, unit.Provenance, time.Now().UTC(), !deps.DryRun)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, group := range groups {
+			for _, bt := range checks.BundleTypes {
+				err = syncBundleType(deps, checks, destinationLists, unit, group, bt, summary)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	summary.Log(deps.Logger, deps.DryRun)
+	return nil
+}
+
+// Loads a source, drops its expired indicators and validates the rest
+func readSource(deps *SyncUmbrellaDependencies, checks *syncChecks, sourceConfig source.Config) (*sourceResult, []Skipped, error) {
+	deps.Logger.Info("Reading source ", sourceConfig.Name, " from ", sourceConfig.Location())
+	indicators, err := deps.SourceLoader.Load(sourceConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	deps.Logger.Info("Read ", len(indicators), " indicators from ", sourceConfig.Name)
+
+	indicators, dropped, err := applyRules(deps, checks.Rules, sourceConfig.Name, indicators)
+	if err != nil {
+		return nil, nil, err
+	}
+	indicators, invalid := normalizeDestinations(indicators)
+	dropped = append(dropped, invalid...)
+
+	indicators, expired, err := expireIndicators(deps, sourceConfig, indicators)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	indicators, skipped := suppressProtected(deps.Logger, checks.Protected, sourceConfig.Name, indicators)
+	skipped = append(dropped, skipped...)
+	indicators, addresses := suppressAddresses(indicators)
+	skipped = append(skipped, addresses...)
+	indicators, suffixSkipped, err := suppressPublicSuffixes(deps.Logger, deps.SuffixList, sourceConfig.Name, indicators)
+	if err != nil {
+		return nil, nil, err
+	}
+	skipped = append(skipped, suffixSkipped...)
+	indicators, ignored, err := suppressIgnored(deps.IgnoreManager, indicators)
+	if err != nil {
+		return nil, nil, err
+	}
+	skipped = append(skipped, ignored...)
+	indicators, held, err := holdPopular(deps.Logger, checks.Popularity, sourceConfig.Name, indicators)
+	if err != nil {
+		return nil, nil, err
+	}
+	skipped = append(skipped, held...)
+	logSkipped(deps.Logger, sourceConfig.Name, skipped, deps.DryRun)
+	if checks.FailOnProtected {
+		err = protectedError(sourceConfig.Name, skipped)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return &sourceResult{Config: sourceConfig, Indicators: indicators, Expired: expired}, skipped, nil
+}
+
+// Plans and applies the changes to the lists of one bundle type for a route of a unit
+func syncBundleType(deps *SyncUmbrellaDependencies, checks *syncChecks, destinationLists []umbrella.DestinationList, unit *syncUnit, group *routeGroup, bt bundleType, summary *Summary) error {
+	entries, skipped := routeEntries(source.Values(group.Indicators), bt)
+	if len(skipped) != 0 {
+		deps.Logger.Warn(len(skipped), " URLs in ", unit.Name, " skipped, ", bt.Name, " lists can only hold domains")
+	}
+
+	var compacted []Skipped
+	if checks.Compact {
+		canPromote := func(parent string) (bool, error) {
+			return canBlock(deps, checks, parent)
+		}
+		var err error
+		entries, compacted, err = compactEntries(entries, checks.Siblings, canPromote)
 		if err != nil {
 			return err
 		}
-		fileData, err := fileManager.ReadFile(filepath)
+	}
+
+	// Lists from before routing belong to the default route
+	legacyName := unit.LegacyName
+	if group.List != "" {
+		legacyName = ""
+	}
+	base := group.base(bt, unit.Name)
+	shards := findShards(filterBundleType(destinationLists, bt), base, legacyName)
+
+	for _, s := range shards {
+		err := fetchDestinations(deps, s, checks.CacheMaxAge)
 		if err != nil {
 			return err
 		}
+	}
 
-		// Sync blockfile
-		deps.Logger.Info("Syncing file ", filepath)
-		var matchingDestinationList umbrella.DestinationList
+	shards, plan := planShards(unit.Name, bt, shards, base, entries, checks.ShardSize)
+	plan.Indicators = group.Indicators
+	if len(checks.Routes) != 0 {
+		plan.Route = group.String()
+	}
+	plan.Expired = unit.Expired
+	plan.Compacted = compacted
+	plan.Log(deps.Logger, deps.DryRun)
+	summary.AddPlan(plan)
 
-		for _, dl := range destinationLists {
-			if strings.Contains(dl.Name, fileInfo.Name()) {
-				deps.Logger.Info("Found matching destination list ", dl.Name)
-				matchingDestinationList = dl
-				break
-			}
+	if deps.DryRun || !plan.HasChanges() {
+		return nil
+	}
+	return applyPlan(deps, shards, plan)
+}
+
+// Records when each indicator was first seen and drops the expired ones
+func expireIndicators(deps *SyncUmbrellaDependencies, sourceConfig source.Config, indicators []source.Indicator) ([]source.Indicator, map[string]time.Time, error) {
+	var ttl time.Duration
+	if sourceConfig.TTL != "" {
+		var err error
+		ttl, err = source.ParseDuration(sourceConfig.TTL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid ttl for source %s: %w", sourceConfig.Name, err)
 		}
+	}
 
-		// if no match is found, create a new destination list
-		if matchingDestinationList == (umbrella.DestinationList{}) {
-			var err error
-			matchingDestinationList, err = deps.UmbrellaConnector.CreateDestinationList("block", false, "SOC Block "+fileInfo.Name())
-			deps.Logger.Info("Created destination list: ", matchingDestinationList.Name)
-			if err != nil {
-				return err
+	now := time.Now().UTC()
+	records, err := deps.StateManager.Observe(sourceConfig.Name, source.Values(indicators), now, !deps.DryRun)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	active, expired := splitExpired(indicators, records, ttl, now)
+	if len(expired) != 0 {
+		deps.Logger.Info(len(expired), " indicators in ", sourceConfig.Name, " have expired")
+	}
+	return active, expired, nil
+}
+
+// Reads the sources from config.yaml. Paths listed under "files" are text file sources.
+func loadSourceConfigs(cm *configurationManager.ConfigurationManager) ([]source.Config, error) {
+	var configs []source.Config
+
+	if files := cm.Get("files"); files != nil {
+		values, ok := files.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Could not get files from config.yaml")
+		}
+		for i, v := range values {
+			str, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("Element at index %d is not a string", i)
 			}
+			configs = append(configs, source.Config{Path: str})
 		}
+	}
 
-		deps.Logger.Info("Reading ", matchingDestinationList.Meta.DestinationCount, " destinations from ", matchingDestinationList.Name)
-		destinations, err := deps.UmbrellaConnector.GetDestinations(matchingDestinationList.ID, 100)
+	var sources []source.Config
+	err := cm.UnmarshalKey("sources", &sources)
+	if err != nil {
+		return nil, fmt.Errorf("Could not get sources from config.yaml: %w", err)
+	}
+	configs = append(configs, sources...)
+
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("No files or sources configured in config.yaml")
+	}
+
+	// Names key the lists and the state of a source, so they must not collide
+	var expanded []source.Config
+	names := make(map[string]bool)
+	for _, cfg := range configs {
+		perFile, err := source.Expand(cfg)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		for _, sourceConfig := range perFile {
+			if names[sourceConfig.Name] {
+				return nil, fmt.Errorf("Duplicate source name %s in config.yaml, set a name for the source reading %s", sourceConfig.Name, sourceConfig.Location())
+			}
+			names[sourceConfig.Name] = true
 		}
+		expanded = append(expanded, perFile...)
+	}
+	return expanded, nil
+}
+
+// Spreads the source entries over the shards and diffs each shard against its current contents
+func planShards(source string, bt bundleType, shards []*shard, base string, entries []string, shardSize int) ([]*shard, *Plan) {
+	shards, membership := assignShards(shards, base, entries, shardSize)
 
-		var destinationData []string
-		for _, destination := range destinations {
-			destinationData = append(destinationData, destination.Destination)
+	plan := &Plan{Source: source, BundleType: bt}
+	for _, s := range shards {
+		var existing []string
+		for _, destination := range s.Destinations {
+			existing = append(existing, destination.Destination)
 		}
 
-		fileLines := fileManager.ToLines(fileData)
-		destinationsToAdd, destinationsToRemove := compareLists(fileLines, destinationData)
+		adds, removes := compareLists(membership[s.Index], existing)
+		plan.Lists = append(plan.Lists, &ListPlan{
+			Name:    s.Name,
+			Shard:   s.Index,
+			ListID:  s.List.ID,
+			Adds:    adds,
+			Removes: removes,
+		})
+	}
+
+	return shards, plan
+}
+
+func applyPlan(deps *SyncUmbrellaDependencies, shards []*shard, plan *Plan) error {
+	for i, lp := range plan.Lists {
+		s := shards[i]
 
-		if len(destinationsToAdd) != 0 {
-			deps.Logger.Info(len(destinationsToAdd), " destinations missing from ", matchingDestinationList.Name)
-			matchingDestinationList, err = deps.UmbrellaConnector.AddDestinations(matchingDestinationList, destinationsToAdd, 500)
+		// Create the destination list for shards that do not exist yet
+		if s.List.ID == 0 {
+			var err error
+			s.List, err = deps.UmbrellaConnector.CreateDestinationList("block", false, s.Name, plan.BundleType.ID)
 			if err != nil {
 				return err
 			}
+			deps.Logger.Info("Created destination list: ", s.List.Name)
 		}
 
-		if len(destinationsToRemove) != 0 {
-			deps.Logger.Info(len(destinationsToRemove), " destinations missing from ", filepath)
-			matchingDestinationList, err = deps.UmbrellaConnector.DeleteDestinations(matchingDestinationList, destinationsToRemove, destinations, 500)
-			if err != nil {
-				return err
+		added, err := applyListPlan(deps, s, plan, lp)
+		if err != nil {
+			// The list is partly changed, so the next run reads it in full
+			if cacheErr := deps.CacheManager.Invalidate(s.List.ID); cacheErr != nil {
+				deps.Logger.Warn("Could not invalidate cache for ", s.Name, ": ", cacheErr)
 			}
+			return err
 		}
 
-		//deps.Logger.Debug("Sleeping for 60 seconds")
-		//time.Sleep(60 * time.Second)
+		err = updateCache(deps, s, lp, added)
+		if err != nil {
+			deps.Logger.Warn("Could not update cache for ", s.Name, ": ", err)
+		}
 	}
 
 	return nil
 }
 
-// Compares BlockFile with Destinations from DestinationList
-func compareLists(blocklistData []string, destinationListData []string) ([]string, []string) {
-	var destsToAdd, destsToDelete []string
+// Removes and adds the destinations of one shard, returning the adds Umbrella
+// accepted. Removes go first so a full shard has room for its adds.
+func applyListPlan(deps *SyncUmbrellaDependencies, s *shard, plan *Plan, lp *ListPlan) ([]string, error) {
+	var err error
+	if len(lp.Removes) != 0 {
+		// Destinations added from the cache have no ID yet, so re-read the list to remove them
+		if !hasIDs(s.Destinations, lp.Removes) {
+			s.Destinations, err = deps.UmbrellaConnector.GetDestinations(s.List.ID, 100)
+			if err != nil {
+				return nil, err
+			}
+		}
 
-	blMap, dlMap := make(map[string]bool), make(map[string]bool)
+		deps.Logger.Info(len(lp.Removes), " destinations no longer in ", plan.Source, " removed from ", s.Name)
+		s.List, err = deps.UmbrellaConnector.DeleteDestinations(s.List, lp.Removes, s.Destinations, 500)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	for _, item := range blocklistData {
-		blMap[item] = true
+	var added []string
+	if len(lp.Adds) != 0 {
+		deps.Logger.Info(len(lp.Adds), " destinations missing from ", s.Name)
+		s.List, added, err = deps.UmbrellaConnector.AddDestinations(s.List, plan.NewDestinations(lp), 500)
+		if err != nil {
+			return nil, err
+		}
 	}
+	return added, nil
+}
 
-	for _, item := range destinationListData {
-		dlMap[item] = true
+// Moves high volume domains from config.yaml into the ignore list data file
+func migrateHighVolumeDomains(deps *SyncCommandDependencies) error {
+	values, ok := deps.ConfigurationManager.Get("highvolumedomains").([]interface{})
+	if !ok || len(values) == 0 {
+		return nil
 	}
 
-	for key := range blMap {
-		if !dlMap[key] {
-			destsToAdd = append(destsToAdd, key)
-		}
+	var entries []ignoreManager.Entry
+	for _, v := range values {
+		entries = append(entries, ignoreManager.Entry{
+			Domain:  fmt.Sprint(v),
+			Kind:    ignoreManager.HighVolume,
+			Message: "migrated from config.yaml",
+		})
 	}
 
-	for key := range dlMap {
-		if !blMap[key] {
-			destsToDelete = append(destsToDelete, key)
+	added, err := deps.IgnoreManager.Import(entries)
+	if err != nil {
+		return err
+	}
+	deps.Logger.Info("Migrated ", added, " high volume domains from config.yaml to the ignore list")
+
+	return deps.ConfigurationManager.Clear("highvolumedomains")
+}
+
+// Compares BlockFile with Destinations from DestinationList. Sorted copies of
+// both lists are walked together, leaving the callers' lists in their order.
+func compareLists(blocklistData []string, destinationListData []string) ([]string, []string) {
+	var destsToAdd, destsToDelete []string
+
+	blocklistData = sortedCopy(blocklistData)
+	destinationListData = sortedCopy(destinationListData)
+
+	i, j := 0, 0
+	for i < len(blocklistData) || j < len(destinationListData) {
+		switch {
+		case j == len(destinationListData) || (i < len(blocklistData) && blocklistData[i] < destinationListData[j]):
+			destsToAdd = appendUnique(destsToAdd, blocklistData[i])
+			i++
+		case i == len(blocklistData) || destinationListData[j] < blocklistData[i]:
+			destsToDelete = appendUnique(destsToDelete, destinationListData[j])
+			j++
+		default:
+			// Present in both, skipping any duplicates of the value on either side
+			value := blocklistData[i]
+			for i < len(blocklistData) && blocklistData[i] == value {
+				i++
+			}
+			for j < len(destinationListData) && destinationListData[j] == value {
+				j++
+			}
 		}
 	}
 
 	return destsToAdd, destsToDelete
 }
+
+func sortedCopy(list []string) []string {
+	sorted := make([]string, len(list))
+	copy(sorted, list)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// Appends a value to a sorted list unless it is already the last one
+func appendUnique(list []string, value string) []string {
+	if len(list) > 0 && list[len(list)-1] == value {
+		return list
+	}
+	return append(list, value)
+}
@@ -0,0 +1,255 @@
+package sync
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/ignoreManager"
+	"github.com/thegrumpyape/umbrellasync/pkg/rules"
+	"github.com/thegrumpyape/umbrellasync/pkg/source"
+	"github.com/thegrumpyape/umbrellasync/pkg/stateManager"
+	"github.com/thegrumpyape/umbrellasync/pkg/umbrella"
+	"github.com/thegrumpyape/umbrellasync/pkg/validation"
+)
+
+type testLogger struct{}
+
+func (testLogger) Debug(args ...interface{}) {}
+func (testLogger) Info(args ...interface{})  {}
+func (testLogger) Warn(args ...interface{})  {}
+func (testLogger) Error(args ...interface{}) {}
+
+func TestCompareLists(t *testing.T) {
+	tests := []struct {
+		name     string
+		desired  []string
+		existing []string
+		adds     []string
+		removes  []string
+	}{
+		{name: "empty", desired: nil, existing: nil},
+		{name: "all new", desired: []string{"b.com", "a.com"}, adds: []string{"a.com", "b.com"}},
+		{name: "all removed", existing: []string{"b.com", "a.com"}, removes: []string{"a.com", "b.com"}},
+		{
+			name:     "overlap",
+			desired:  []string{"c.com", "a.com", "b.com"},
+			existing: []string{"d.com", "b.com", "a.com"},
+			adds:     []string{"c.com"},
+			removes:  []string{"d.com"},
+		},
+		{
+			name:     "duplicates on either side",
+			desired:  []string{"a.com", "c.com", "a.com", "c.com"},
+			existing: []string{"b.com", "a.com", "b.com", "a.com"},
+			adds:     []string{"c.com"},
+			removes:  []string{"b.com"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			desired := append([]string(nil), test.desired...)
+			existing := append([]string(nil), test.existing...)
+
+			adds, removes := compareLists(desired, existing)
+			if !reflect.DeepEqual(adds, test.adds) {
+				t.Errorf("adds = %v, want %v", adds, test.adds)
+			}
+			if !reflect.DeepEqual(removes, test.removes) {
+				t.Errorf("removes = %v, want %v", removes, test.removes)
+			}
+			if !reflect.DeepEqual(desired, test.desired) || !reflect.DeepEqual(existing, test.existing) {
+				t.Errorf("compareLists() reordered its arguments to %v and %v", desired, existing)
+			}
+		})
+	}
+}
+
+// Plans a sync, applies it the way the connector sends destinations and plans
+// again over the result, which must change nothing
+func TestPlanAfterSync(t *testing.T) {
+	var indicators []source.Indicator
+	for _, value := range []string{
+		"evil.com",
+		"Evil.COM.",
+		"http://evil.com/#frag",
+		"http://evil.com:8080/x",
+		"http://evil.com/x#top",
+		"https://Bad.org/Path?id=1",
+		"bad.org/login",
+		"http:///nohost",
+	} {
+		indicators = append(indicators, source.Indicator{Value: value})
+	}
+
+	indicators, skipped := normalizeDestinations(indicators)
+	if len(skipped) != 1 || skipped[0].Value != "http:///nohost" {
+		t.Errorf("normalizeDestinations() skipped %v, want only http:///nohost", skipped)
+	}
+	want := []string{"evil.com", "http://evil.com/", "http://evil.com/x", "https://bad.org/Path?id=1", "bad.org/login"}
+	if got := source.Values(indicators); !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeDestinations() = %v, want %v", got, want)
+	}
+
+	bt := bundleTypes["web"]
+	shards, plan := planShards("feed", bt, nil, "feed", source.Values(indicators), 2)
+	for i, lp := range plan.Lists {
+		// The list holds each add as the connector sends it
+		for _, add := range lp.Adds {
+			sent, _ := umbrella.NormalizeDestination(add)
+			shards[i].Destinations = append(shards[i].Destinations, umbrella.Destination{Destination: sent})
+		}
+		shards[i].List.ID = i + 1
+	}
+
+	_, plan = planShards("feed", bt, shards, "feed", source.Values(indicators), 2)
+	for _, lp := range plan.Lists {
+		if len(lp.Adds) != 0 || len(lp.Removes) != 0 {
+			t.Errorf("second plan of %s adds %v and removes %v, want no changes", lp.Name, lp.Adds, lp.Removes)
+		}
+	}
+}
+
+// Compares full shards where a tenth of the entries changed since the last sync
+func BenchmarkCompareLists(b *testing.B) {
+	desired := make([]string, maxShardSize)
+	existing := make([]string, maxShardSize)
+	for i := range desired {
+		desired[i] = "host" + strconv.Itoa(i) + ".example.com"
+		existing[i] = "host" + strconv.Itoa(i+maxShardSize/10) + ".example.com"
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		adds, removes := compareLists(desired, existing)
+		if len(adds) != maxShardSize/10 || len(removes) != maxShardSize/10 {
+			b.Fatalf("got %d adds and %d removes, want %d of each", len(adds), len(removes), maxShardSize/10)
+		}
+	}
+}
+
+// Heap a sync may use per distinct indicator of a source, from reading the
+// source to planning its lists. Memory grows with the distinct indicators a
+// source asserts rather than with the size of the file they are read from.
+// The peak assumes the default GOGC.
+const (
+	benchmarkIndicators = 500000
+	maxRetainedPerValue = 384
+	maxPeakPerValue     = 1024
+)
+
+// Reads a feed listing every indicator twice and plans its lists, failing when
+// the heap held afterwards or at the peak exceeds the limits per indicator
+func BenchmarkReadAndPlan(b *testing.B) {
+	dir := b.TempDir()
+	path := filepath.Join(dir, "feed.txt")
+	f, err := os.Create(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	w := bufio.NewWriter(f)
+	for i := 0; i < 2*benchmarkIndicators; i++ {
+		w.WriteString("host" + strconv.Itoa(i%benchmarkIndicators) + ".example.com\n")
+	}
+	if err := w.Flush(); err != nil {
+		b.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		b.Fatal(err)
+	}
+
+	engine, err := rules.New(nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	protected, err := validation.NewProtected(nil, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	checks := &syncChecks{Protected: protected, Rules: engine}
+	cfg := source.Config{Path: path}.WithDefaults()
+
+	b.ReportAllocs()
+	var retained, peak int64
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		deps := &SyncUmbrellaDependencies{
+			IgnoreManager: ignoreManager.New(filepath.Join(dir, "ignore.json")),
+			SourceLoader:  source.NewLoader(dir, testLogger{}),
+			StateManager:  stateManager.New(filepath.Join(dir, "state.json")),
+			SuffixList:    validation.NewSuffixList(filepath.Join(dir, "public_suffix_list.dat")),
+			Logger:        testLogger{},
+			DryRun:        true,
+		}
+		var before runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		stop := samplePeakHeap()
+		b.StartTimer()
+
+		result, _, err := readSource(deps, checks, cfg)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, plan := planShards(cfg.Name, bundleTypes["dns"], nil, cfg.Name, source.Values(result.Indicators), maxShardSize)
+
+		b.StopTimer()
+		peak = stop() - int64(before.HeapAlloc)
+		if len(result.Indicators) != benchmarkIndicators || len(plan.Lists) != benchmarkIndicators/maxShardSize {
+			b.Fatalf("read %d indicators into %d lists, want %d into %d", len(result.Indicators), len(plan.Lists), benchmarkIndicators, benchmarkIndicators/maxShardSize)
+		}
+		var after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		retained = int64(after.HeapAlloc) - int64(before.HeapAlloc)
+		runtime.KeepAlive(deps)
+		runtime.KeepAlive(result)
+		runtime.KeepAlive(plan)
+		b.StartTimer()
+	}
+
+	b.ReportMetric(float64(retained)/benchmarkIndicators, "retained-B/indicator")
+	b.ReportMetric(float64(peak)/benchmarkIndicators, "peak-B/indicator")
+	if retained > maxRetainedPerValue*benchmarkIndicators {
+		b.Errorf("sync holds %d bytes per indicator, over the limit of %d", retained/benchmarkIndicators, maxRetainedPerValue)
+	}
+	if peak > maxPeakPerValue*benchmarkIndicators {
+		b.Errorf("sync peaked at %d bytes per indicator, over the limit of %d", peak/benchmarkIndicators, maxPeakPerValue)
+	}
+}
+
+// Samples the heap in the background until the returned function is called,
+// which returns the largest heap seen
+func samplePeakHeap() func() int64 {
+	done := make(chan struct{})
+	result := make(chan int64)
+	go func() {
+		var peak int64
+		var stats runtime.MemStats
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			runtime.ReadMemStats(&stats)
+			if int64(stats.HeapAlloc) > peak {
+				peak = int64(stats.HeapAlloc)
+			}
+			select {
+			case <-done:
+				result <- peak
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() int64 {
+		close(done)
+		return <-result
+	}
+}
@@ -0,0 +1,278 @@
+package sync
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/configurationManager"
+	"github.com/thegrumpyape/umbrellasync/pkg/source"
+)
+
+const reasonPending = "pending consensus"
+
+// targetConfig aggregates several sources into one family of lists. With
+// consensus settings, indicators from the consensus sources are only synced
+// when at least Consensus of them agree or their weighted confidence exceeds
+// MinScore. Sources outside ConsensusSources are trusted on their own.
+type targetConfig struct {
+	Name             string         `mapstructure:"name"`
+	Sources          []string       `mapstructure:"sources"`
+	Consensus        int            `mapstructure:"consensus"`
+	ConsensusSources []string       `mapstructure:"consensussources"`
+	Weights          []sourceWeight `mapstructure:"weights"`
+	MinScore         float64        `mapstructure:"minscore"`
+}
+
+// sourceWeight is an entry of the weights of a target. It is a list rather
+// than a map since config keys are lowercased and source names are not.
+type sourceWeight struct {
+	Source string  `mapstructure:"source"`
+	Weight float64 `mapstructure:"weight"`
+}
+
+// Returns the weight of a source in the consensus score, 1 by default
+func (t targetConfig) weight(sourceName string) float64 {
+	for _, w := range t.Weights {
+		if w.Source == sourceName {
+			return w.Weight
+		}
+	}
+	return 1
+}
+
+func (t targetConfig) hasConsensus() bool {
+	return t.Consensus > 0 || t.MinScore > 0
+}
+
+// Returns the sources that have to agree, every source of the target by default
+func (t targetConfig) consensusSet() map[string]bool {
+	names := t.ConsensusSources
+	if len(names) == 0 {
+		names = t.Sources
+	}
+	set := make(map[string]bool)
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+// sourceResult holds the indicators of a source that passed validation
+type sourceResult struct {
+	Config     source.Config
+	Indicators []source.Indicator
+	Expired    map[string]time.Time
+}
+
+// syncUnit is what a family of lists is synced from, either a single source
+// or a target aggregating several sources
+type syncUnit struct {
+	Name       string
+	LegacyName string
+	Target     bool
+	Indicators []source.Indicator
+	Expired    map[string]time.Time
+	Provenance map[string][]string
+	Pending    []Skipped
+}
+
+// Reads the targets from config.yaml and checks that their sources exist
+func loadTargets(cm *configurationManager.ConfigurationManager, sourceConfigs []source.Config) ([]targetConfig, error) {
+	var targets []targetConfig
+	err := cm.UnmarshalKey("targets", &targets)
+	if err != nil {
+		return nil, fmt.Errorf("Could not get targets from config.yaml: %w", err)
+	}
+
+	sourceNames := make(map[string]bool)
+	for _, sourceConfig := range sourceConfigs {
+		sourceNames[sourceConfig.Name] = true
+	}
+
+	targetNames := make(map[string]bool)
+	for _, target := range targets {
+		if target.Name == "" {
+			return nil, fmt.Errorf("target without a name in config.yaml")
+		}
+		if targetNames[target.Name] {
+			return nil, fmt.Errorf("target %s is configured twice", target.Name)
+		}
+		targetNames[target.Name] = true
+		if len(target.Sources) == 0 {
+			return nil, fmt.Errorf("target %s has no sources", target.Name)
+		}
+		members := make(map[string]bool)
+		for _, name := range target.Sources {
+			if !sourceNames[name] {
+				return nil, fmt.Errorf("target %s uses unknown source %s", target.Name, name)
+			}
+			members[name] = true
+		}
+		for _, name := range target.ConsensusSources {
+			if !members[name] {
+				return nil, fmt.Errorf("consensus source %s is not a source of target %s", name, target.Name)
+			}
+		}
+		weighted := make(map[string]bool)
+		for _, w := range target.Weights {
+			if !members[w.Source] {
+				return nil, fmt.Errorf("weighted source %s is not a source of target %s", w.Source, target.Name)
+			}
+			if weighted[w.Source] {
+				return nil, fmt.Errorf("source %s is weighted twice in target %s", w.Source, target.Name)
+			}
+			weighted[w.Source] = true
+		}
+		if target.Consensus < 0 || target.MinScore < 0 {
+			return nil, fmt.Errorf("consensus and minscore of target %s must not be negative", target.Name)
+		}
+	}
+	return targets, nil
+}
+
+// Groups the sources into units. Sources in a target are synced through it,
+// every other source keeps its own lists.
+func buildUnits(targets []targetConfig, results []*sourceResult) ([]*syncUnit, error) {
+	byName := make(map[string]*sourceResult)
+	inTarget := make(map[string]bool)
+	for _, result := range results {
+		byName[result.Config.Name] = result
+	}
+	for _, target := range targets {
+		for _, name := range target.Sources {
+			inTarget[name] = true
+		}
+	}
+
+	var units []*syncUnit
+	names := make(map[string]bool)
+	for _, result := range results {
+		if inTarget[result.Config.Name] {
+			continue
+		}
+		names[result.Config.Name] = true
+		units = append(units, &syncUnit{
+			Name:       result.Config.Name,
+			LegacyName: result.Config.Name,
+			Indicators: result.Indicators,
+			Expired:    result.Expired,
+		})
+	}
+
+	for _, target := range targets {
+		if names[target.Name] {
+			return nil, fmt.Errorf("target %s has the same name as a source, their lists would collide", target.Name)
+		}
+		var members []*sourceResult
+		for _, name := range target.Sources {
+			members = append(members, byName[name])
+		}
+		units = append(units, aggregate(target, members))
+	}
+	return units, nil
+}
+
+// Merges the indicators of the sources in a target. An indicator keeps the
+// metadata of the first source asserting it and records every source
+// asserting it, so it is only removed once no source asserts it any more.
+func aggregate(target targetConfig, members []*sourceResult) *syncUnit {
+	unit := &syncUnit{
+		Name:       target.Name,
+		Target:     true,
+		Expired:    make(map[string]time.Time),
+		Provenance: make(map[string][]string),
+	}
+
+	index := make(map[string]int)
+	var indicators []source.Indicator
+	votes := make(map[string]*consensusVote)
+	consensus := target.consensusSet()
+	for _, member := range members {
+		for _, indicator := range member.Indicators {
+			unit.Provenance[indicator.Value] = append(unit.Provenance[indicator.Value], member.Config.Name)
+			votes[indicator.Value] = votes[indicator.Value].add(target, consensus, member.Config.Name, indicator)
+			if _, ok := index[indicator.Value]; ok {
+				continue
+			}
+			index[indicator.Value] = len(indicators)
+			indicators = append(indicators, indicator)
+		}
+	}
+
+	for _, indicator := range indicators {
+		if vote := votes[indicator.Value]; target.hasConsensus() && !vote.agreed(target) {
+			unit.Pending = append(unit.Pending, Skipped{Value: indicator.Value, Reason: reasonPending, Detail: vote.detail(len(consensus))})
+			delete(unit.Provenance, indicator.Value)
+			continue
+		}
+		unit.Indicators = append(unit.Indicators, indicator)
+	}
+
+	for i, indicator := range unit.Indicators {
+		metadata := make(map[string]string, len(indicator.Metadata)+1)
+		for k, v := range indicator.Metadata {
+			metadata[k] = v
+		}
+		sources := unit.Provenance[indicator.Value]
+		sort.Strings(sources)
+		metadata["sources"] = strings.Join(sources, ",")
+		unit.Indicators[i] = source.Indicator{Value: indicator.Value, Metadata: metadata}
+	}
+
+	// An expiry only shows when no other source still asserts the indicator
+	for _, member := range members {
+		for value, expires := range member.Expired {
+			if _, ok := index[value]; !ok {
+				unit.Expired[value] = expires
+			}
+		}
+	}
+	return unit
+}
+
+// consensusVote tallies the consensus sources asserting an indicator. A nil
+// vote has no agreeing sources yet.
+type consensusVote struct {
+	Sources int
+	Score   float64
+	Trusted bool
+}
+
+// Adds the vote of a source. Confidence is read from the indicator metadata
+// on a 0-100 scale and counts as 100 when the source does not provide it.
+func (v *consensusVote) add(target targetConfig, consensus map[string]bool, sourceName string, indicator source.Indicator) *consensusVote {
+	if v == nil {
+		v = &consensusVote{}
+	}
+	if !consensus[sourceName] {
+		v.Trusted = true
+		return v
+	}
+
+	confidence := 100.0
+	if value, ok := indicator.Metadata["confidence"]; ok {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			confidence = parsed
+		}
+	}
+	v.Sources++
+	v.Score += target.weight(sourceName) * confidence / 100
+	return v
+}
+
+func (v *consensusVote) agreed(target targetConfig) bool {
+	if v.Trusted {
+		return true
+	}
+	if target.Consensus > 0 && v.Sources >= target.Consensus {
+		return true
+	}
+	return target.MinScore > 0 && v.Score > target.MinScore
+}
+
+func (v *consensusVote) detail(total int) string {
+	return fmt.Sprintf("%d of %d sources agree, score %.2f", v.Sources, total, v.Score)
+}
@@ -0,0 +1,225 @@
+package sync
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/thegrumpyape/umbrellasync/pkg/configurationManager"
+	"github.com/thegrumpyape/umbrellasync/pkg/source"
+)
+
+func TestLoadTargets(t *testing.T) {
+	sources := []source.Config{{Name: "OSINT.txt"}, {Name: "vendor"}}
+
+	tests := []struct {
+		name    string
+		config  string
+		weights []sourceWeight
+		wantErr string
+	}{
+		{
+			name: "mixed case source names",
+			config: `
+targets:
+  - name: combined
+    sources: [OSINT.txt, vendor]
+    minscore: 1
+    weights:
+      - source: OSINT.txt
+        weight: 0.5
+`,
+			weights: []sourceWeight{{Source: "OSINT.txt", Weight: 0.5}},
+		},
+		{
+			name: "weighted source outside the target",
+			config: `
+targets:
+  - name: combined
+    sources: [vendor]
+    weights:
+      - source: OSINT.txt
+        weight: 2
+`,
+			wantErr: "weighted source OSINT.txt is not a source of target combined",
+		},
+		{
+			name: "source weighted twice",
+			config: `
+targets:
+  - name: combined
+    sources: [OSINT.txt, vendor]
+    weights:
+      - {source: vendor, weight: 2}
+      - {source: vendor, weight: 3}
+`,
+			wantErr: "source vendor is weighted twice in target combined",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Cleanup(viper.Reset)
+			viper.SetConfigType("yaml")
+			if err := viper.ReadConfig(strings.NewReader(test.config)); err != nil {
+				t.Fatal(err)
+			}
+
+			targets, err := loadTargets(configurationManager.New(), sources)
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Fatalf("loadTargets() error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadTargets() error = %v", err)
+			}
+			if !reflect.DeepEqual(targets[0].Weights, test.weights) {
+				t.Errorf("weights = %v, want %v", targets[0].Weights, test.weights)
+			}
+		})
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	expired := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	members := []*sourceResult{
+		{
+			Config: source.Config{Name: "b"},
+			Indicators: []source.Indicator{
+				{Value: "shared.com", Metadata: map[string]string{"ticket": "INC1"}},
+				{Value: "only-b.com"},
+			},
+			Expired: map[string]time.Time{"gone.com": expired, "still.com": expired},
+		},
+		{
+			Config: source.Config{Name: "a"},
+			Indicators: []source.Indicator{
+				{Value: "shared.com", Metadata: map[string]string{"ticket": "INC2"}},
+				{Value: "still.com"},
+			},
+		},
+	}
+
+	unit := aggregate(targetConfig{Name: "combined", Sources: []string{"b", "a"}}, members)
+	if !unit.Target || unit.Name != "combined" {
+		t.Errorf("aggregate() = unit %q, target %v", unit.Name, unit.Target)
+	}
+
+	want := []source.Indicator{
+		{Value: "shared.com", Metadata: map[string]string{"ticket": "INC1", "sources": "a,b"}},
+		{Value: "only-b.com", Metadata: map[string]string{"sources": "b"}},
+		{Value: "still.com", Metadata: map[string]string{"sources": "a"}},
+	}
+	if !reflect.DeepEqual(unit.Indicators, want) {
+		t.Errorf("indicators = %v, want %v", unit.Indicators, want)
+	}
+	if !reflect.DeepEqual(unit.Provenance["shared.com"], []string{"a", "b"}) {
+		t.Errorf("provenance of shared.com = %v, want [a b]", unit.Provenance["shared.com"])
+	}
+	if !reflect.DeepEqual(unit.Expired, map[string]time.Time{"gone.com": expired}) {
+		t.Errorf("expired = %v, want only gone.com", unit.Expired)
+	}
+	if members[0].Indicators[0].Metadata["sources"] != "" {
+		t.Errorf("aggregate() changed the metadata of source b")
+	}
+}
+
+func TestConsensusVote(t *testing.T) {
+	confidence := func(value string) map[string]string { return map[string]string{"confidence": value} }
+	members := []*sourceResult{
+		{Config: source.Config{Name: "OSINT"}, Indicators: []source.Indicator{
+			{Value: "one.com"}, {Value: "two.com"}, {Value: "low.com", Metadata: confidence("40")},
+		}},
+		{Config: source.Config{Name: "vendor"}, Indicators: []source.Indicator{
+			{Value: "two.com"}, {Value: "low.com", Metadata: confidence("40")},
+		}},
+		{Config: source.Config{Name: "internal"}, Indicators: []source.Indicator{
+			{Value: "trusted.com"},
+		}},
+	}
+	sources := []string{"OSINT", "vendor", "internal"}
+	consensusSources := []string{"OSINT", "vendor"}
+
+	tests := []struct {
+		name    string
+		target  targetConfig
+		synced  string
+		pending map[string]string
+	}{
+		{
+			name:   "no consensus syncs everything",
+			target: targetConfig{Sources: sources},
+			synced: "one.com,two.com,low.com,trusted.com",
+		},
+		{
+			name:   "two sources have to agree",
+			target: targetConfig{Sources: sources, Consensus: 2, ConsensusSources: consensusSources},
+			synced: "two.com,low.com,trusted.com",
+			pending: map[string]string{
+				"one.com": "1 of 2 sources agree, score 1.00",
+			},
+		},
+		{
+			name:   "score above the minimum",
+			target: targetConfig{Sources: sources, MinScore: 1.5, ConsensusSources: consensusSources},
+			synced: "two.com,trusted.com",
+			pending: map[string]string{
+				"one.com": "1 of 2 sources agree, score 1.00",
+				"low.com": "2 of 2 sources agree, score 0.80",
+			},
+		},
+		{
+			name: "weighted score",
+			target: targetConfig{
+				Sources:          sources,
+				MinScore:         1.5,
+				ConsensusSources: consensusSources,
+				Weights:          []sourceWeight{{Source: "OSINT", Weight: 2}},
+			},
+			synced: "one.com,two.com,trusted.com",
+			pending: map[string]string{
+				"low.com": "2 of 2 sources agree, score 1.20",
+			},
+		},
+		{
+			name:   "every source votes by default",
+			target: targetConfig{Sources: sources, Consensus: 2},
+			synced: "two.com,low.com",
+			pending: map[string]string{
+				"one.com":     "1 of 3 sources agree, score 1.00",
+				"trusted.com": "1 of 3 sources agree, score 1.00",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.target.Name = "combined"
+			unit := aggregate(test.target, members)
+			if got := strings.Join(source.Values(unit.Indicators), ","); got != test.synced {
+				t.Errorf("synced = %q, want %q", got, test.synced)
+			}
+
+			pending := make(map[string]string)
+			for _, s := range unit.Pending {
+				if s.Reason != reasonPending {
+					t.Errorf("%s skipped as %q, want %q", s.Value, s.Reason, reasonPending)
+				}
+				if _, ok := unit.Provenance[s.Value]; ok {
+					t.Errorf("pending %s still has a provenance", s.Value)
+				}
+				pending[s.Value] = s.Detail
+			}
+			if test.pending == nil {
+				test.pending = map[string]string{}
+			}
+			if !reflect.DeepEqual(pending, test.pending) {
+				t.Errorf("pending = %v, want %v", pending, test.pending)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package toplist
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/thegrumpyape/umbrellasync/pkg/validation"
+)
+
+type ToplistCommandDependencies struct {
+	Ranking *validation.Ranking
+}
+
+func New(deps *ToplistCommandDependencies) *cobra.Command {
+	toplistCommand := &cobra.Command{
+		Use:   "toplist",
+		Short: "Top sites ranking management",
+		Long:  "Manage the top sites ranking used to hold popular domains for review instead of blocking them",
+	}
+
+	toplistCommand.AddCommand(NewImportCommand(deps))
+	toplistCommand.AddCommand(NewCheckCommand(deps))
+
+	return toplistCommand
+}
+
+func NewImportCommand(deps *ToplistCommandDependencies) *cobra.Command {
+	importCommand := &cobra.Command{
+		Use:   "import",
+		Short: "Import a top sites ranking",
+		Long:  "Import a Tranco or Umbrella Top 1M style CSV file of rank,domain rows, replacing the current ranking",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			count, err := deps.Ranking.Import(file)
+			if err != nil {
+				return err
+			}
+			fmt.Println("Imported", count, "ranked domains")
+			return nil
+		},
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("requires exactly 1 argument")
+			}
+			return nil
+		},
+	}
+
+	return importCommand
+}
+
+func NewCheckCommand(deps *ToplistCommandDependencies) *cobra.Command {
+	checkCommand := &cobra.Command{
+		Use:   "check",
+		Short: "Show the rank of destinations",
+		Long:  "Show the rank of each destination in the imported top sites ranking",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			available, err := deps.Ranking.Available()
+			if err != nil {
+				return err
+			}
+			if !available {
+				return errors.New("no ranking imported, run umbrellasync toplist import first")
+			}
+
+			for _, destination := range args {
+				domain, rank, ok, err := deps.Ranking.Rank(destination)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					fmt.Printf("%s: not ranked\n", destination)
+					continue
+				}
+				fmt.Printf("%s: ranked %d as %s\n", destination, rank, domain)
+			}
+			return nil
+		},
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return errors.New("requires at least 1 argument")
+			}
+			return nil
+		},
+	}
+
+	return checkCommand
+}
@@ -0,0 +1,82 @@
+package cacheManager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/fileManager"
+	"github.com/thegrumpyape/umbrellasync/pkg/umbrella"
+)
+
+// ListCache is the last known contents of a destination list, tagged with the
+// list metadata it was read under
+type ListCache struct {
+	ListID           int                    `json:"listId"`
+	ModifiedAt       int                    `json:"modifiedAt"`
+	DestinationCount int                    `json:"destinationCount"`
+	FullFetchAt      time.Time              `json:"fullFetchAt"`
+	Destinations     []umbrella.Destination `json:"destinations"`
+}
+
+// CacheManager stores one file per destination list in dir
+type CacheManager struct {
+	dir string
+}
+
+func New(dir string) *CacheManager {
+	return &CacheManager{dir: dir}
+}
+
+// Loads the cache for a list, returning nil if there is none
+func (cm *CacheManager) Load(listID int) (*ListCache, error) {
+	path := cm.path(listID)
+	exists, err := fileManager.IsExists(path)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	content, err := fileManager.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cache ListCache
+	err = json.Unmarshal(content, &cache)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cache %s: %w", path, err)
+	}
+	return &cache, nil
+}
+
+func (cm *CacheManager) Save(cache *ListCache) error {
+	content, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return fileManager.WriteFileAtomic(cm.path(cache.ListID), content, 0644)
+}
+
+func (cm *CacheManager) Invalidate(listID int) error {
+	err := os.Remove(cm.path(listID))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Reports whether the cache still matches the list metadata and the last full
+// fetch is recent enough to trust it
+func (lc *ListCache) IsFresh(list umbrella.DestinationList, maxAge time.Duration) bool {
+	return lc.ListID == list.ID &&
+		lc.ModifiedAt == list.ModifiedAt &&
+		lc.DestinationCount == list.Meta.DestinationCount &&
+		len(lc.Destinations) == list.Meta.DestinationCount &&
+		time.Since(lc.FullFetchAt) < maxAge
+}
+
+func (cm *CacheManager) path(listID int) string {
+	return filepath.Join(cm.dir, fmt.Sprintf("%d.json", listID))
+}
@@ -35,6 +35,11 @@ func (cm *ConfigurationManager) InitConfigFile() {
 		log.Fatal(err)
 	}
 
+	err = migrateLegacyHome(configHome)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	configPath = filepath.Join(configHome, configName+"."+configType)
 
 	isDirExists, err := fileManager.IsExists(configHome)
@@ -69,8 +74,55 @@ func (cm *ConfigurationManager) InitConfigFile() {
 	}
 }
 
+// Returns the directory holding config.yaml and the other umbrellasync data files
+func ConfigHome() string {
+	return filepath.Join(fileManager.GetHomedir(), ".umbrellasync")
+}
+
+// Returns the directory earlier versions used. It was joined with
+// backslashes, which outside Windows are part of the name rather than
+// separators, like /home/user\.umbrellasync\ in /home.
+func legacyConfigHome() string {
+	return fileManager.GetHomedir() + "\\.umbrellasync\\"
+}
+
+// Moves the data of an earlier version to ConfigHome so its credentials,
+// ignore list and state are still found. Nothing is moved when ConfigHome
+// already exists.
+func migrateLegacyHome(configHome string) error {
+	legacy := legacyConfigHome()
+	if filepath.Clean(legacy) == filepath.Clean(configHome) {
+		return nil
+	}
+
+	isLegacyExists, err := fileManager.IsExists(legacy)
+	if err != nil || !isLegacyExists {
+		return err
+	}
+	isDirExists, err := fileManager.IsExists(configHome)
+	if err != nil {
+		return err
+	}
+	if isDirExists {
+		log.Printf("Ignoring %s from an earlier version, %s is used instead", legacy, configHome)
+		return nil
+	}
+
+	err = os.Rename(legacy, configHome)
+	if err != nil {
+		return fmt.Errorf("error moving %s to %s: %w", legacy, configHome, err)
+	}
+	log.Printf("Moved the configuration from %s to %s", legacy, configHome)
+	return nil
+}
+
+// Returns the path of a data file stored alongside config.yaml
+func DataPath(name string) string {
+	return filepath.Join(ConfigHome(), name)
+}
+
 func setViperConfig() (string, string, string, error) {
-	configHome := fmt.Sprintf("%s\\.umbrellasync\\", fileManager.GetHomedir())
+	configHome := ConfigHome()
 	configName := "config"
 	configType := "yaml"
 
@@ -119,25 +171,24 @@ func (cm *ConfigurationManager) Prompt() error {
 	// Get Files
 	var files []string
 	fmt.Println("\n\nWhat files do you want to sync?")
+	fmt.Println("Enter a file, a directory, a glob like C:\\intel\\*.txt or - for standard input.")
 	for {
 		filepath, err := utils.GetUserInput("File Path:")
 		if err != nil {
 			return err
 		}
 
-		// Check if file exists
-		if _, err := os.Stat(filepath); err == nil {
-			files = append(files, filepath)
-
-		} else if errors.Is(err, os.ErrNotExist) {
+		ok, err := isSourcePath(filepath)
+		if err != nil {
+			fmt.Println("Something weird happened. Quitting.")
+			return nil
+		}
+		if !ok {
 			fmt.Println("File not found:", filepath)
 			fmt.Println("Please verify path is correct.")
 			continue
-
-		} else {
-			fmt.Println("Something weird happened. Quitting.")
-			return nil
 		}
+		files = append(files, filepath)
 
 		// Ask if another file needs to be added
 		res, err := utils.GetUserInput("Add another file? (Y/n):")
@@ -163,6 +214,27 @@ func (cm *ConfigurationManager) Prompt() error {
 	return nil
 }
 
+// Reports whether a path can be synced: standard input, an existing file or
+// directory, or a glob matching at least one file
+func isSourcePath(path string) (bool, error) {
+	if path == "-" {
+		return true, nil
+	}
+	if strings.ContainsAny(path, "*?[") {
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return false, nil
+		}
+		return len(matches) > 0, nil
+	}
+
+	_, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 func (cm *ConfigurationManager) Set(key string, value string) error {
 	viper.Set(key, value)
 	writeClientIdErr := viper.WriteConfigAs(configPath)
@@ -226,6 +298,50 @@ func (cm *ConfigurationManager) Get(key string) any {
 	return viper.Get(key)
 }
 
+// Returns key as an int, or def when the key is unset
+func (cm *ConfigurationManager) GetInt(key string, def int) int {
+	if !viper.IsSet(key) {
+		return def
+	}
+	return viper.GetInt(key)
+}
+
+// Returns key as a bool, or def when the key is unset
+func (cm *ConfigurationManager) GetBool(key string, def bool) bool {
+	if !viper.IsSet(key) {
+		return def
+	}
+	return viper.GetBool(key)
+}
+
+// Returns key as a list of strings. Accepts a list or a comma separated string.
+func (cm *ConfigurationManager) GetStringList(key string) ([]string, error) {
+	var values []string
+	switch v := viper.Get(key).(type) {
+	case nil:
+	case string:
+		for _, value := range strings.Split(v, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			values = append(values, strings.TrimSpace(fmt.Sprint(value)))
+		}
+	case []string:
+		values = v
+	default:
+		return nil, fmt.Errorf("invalid type for %s: %T", key, v)
+	}
+	return values, nil
+}
+
+// Decodes the value of key into v, which should be a pointer
+func (cm *ConfigurationManager) UnmarshalKey(key string, v interface{}) error {
+	return viper.UnmarshalKey(key, v)
+}
+
 func (cm *ConfigurationManager) Clear(key string) error {
 	fullConfig := viper.AllSettings()
 	delete(fullConfig, key)
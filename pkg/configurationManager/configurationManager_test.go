@@ -0,0 +1,63 @@
+package configurationManager
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestMigrateLegacyHome(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the legacy and current directories are the same on Windows")
+	}
+
+	tests := []struct {
+		name        string
+		legacy      bool
+		current     bool
+		wantCurrent string
+	}{
+		{name: "nothing to move"},
+		{name: "legacy directory is moved", legacy: true, wantCurrent: "legacy"},
+		{name: "current directory wins", legacy: true, current: true, wantCurrent: "current"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("HOME", filepath.Join(t.TempDir(), "user"))
+			if err := os.MkdirAll(os.Getenv("HOME"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			write := func(dir string, content string) {
+				if err := os.MkdirAll(dir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if test.legacy {
+				write(legacyConfigHome(), "legacy")
+			}
+			if test.current {
+				write(ConfigHome(), "current")
+			}
+
+			if err := migrateLegacyHome(ConfigHome()); err != nil {
+				t.Fatalf("migrateLegacyHome() error = %v", err)
+			}
+
+			content, err := os.ReadFile(filepath.Join(ConfigHome(), "config.yaml"))
+			if test.wantCurrent == "" {
+				if !os.IsNotExist(err) {
+					t.Errorf("config.yaml exists after migrating nothing")
+				}
+				return
+			}
+			if err != nil || string(content) != test.wantCurrent {
+				t.Errorf("config.yaml = %q, %v, want %q", content, err, test.wantCurrent)
+			}
+		})
+	}
+}
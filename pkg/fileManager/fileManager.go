@@ -1,13 +1,26 @@
 package fileManager
 
 import (
-	"bytes"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path"
-	"strings"
+	"path/filepath"
+	"time"
 )
 
+const (
+	lockRetryInterval = 50 * time.Millisecond
+	lockTimeout       = 10 * time.Second
+	lockStaleAfter    = 2 * time.Minute
+)
+
+// Longest line a line scanner accepts unless configured otherwise
+const DefaultMaxLineLength = 1024 * 1024
+
 func GetHomedir() string {
 	homedir, err := os.UserHomeDir()
 	if err != nil {
@@ -24,6 +37,72 @@ func WriteToFile(filepath string, content []byte) error {
 	return err
 }
 
+// Writes content to a temporary file and renames it over filename so readers
+// never observe a partially written file
+func WriteFileAtomic(filename string, content []byte, perm os.FileMode) error {
+	filedir := filepath.Dir(filename)
+	err := os.MkdirAll(filedir, 0755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filedir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(content)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tmp.Name(), perm)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// Acquires an exclusive lock file next to filename, waiting for other writers.
+// Locks older than lockStaleAfter are assumed to belong to a crashed process.
+// The returned function releases the lock.
+func LockFile(filename string) (func(), error) {
+	lockPath := filename + ".lock"
+	err := os.MkdirAll(filepath.Dir(lockPath), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			fmt.Fprintf(lock, "%d", os.Getpid())
+			lock.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > lockStaleAfter {
+			os.Remove(lockPath)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock %s", lockPath)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
 func ReadFile(filepath string) ([]byte, error) {
 	content, err := os.ReadFile(filepath)
 	if err != nil {
@@ -69,15 +148,14 @@ func IsExists(path string) (bool, error) {
 	return false, err
 }
 
-func ToLines(content []byte) []string {
-
-	lines := bytes.Split(content, []byte("\n"))
-	var stringLines []string
-	for _, line := range lines {
-		linestring := string(line)
-		linestring = strings.TrimSuffix(linestring, "\r") // For Windows
-		stringLines = append(stringLines, linestring)
+// Returns a scanner reading lines of up to maxLineLength bytes, or
+// DefaultMaxLineLength when it is not positive. Only the current line is held
+// in memory, and a longer line fails the scan with bufio.ErrTooLong.
+func NewLineScanner(r io.Reader, maxLineLength int) *bufio.Scanner {
+	if maxLineLength <= 0 {
+		maxLineLength = DefaultMaxLineLength
 	}
-
-	return stringLines
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, min(64*1024, maxLineLength+1)), maxLineLength+1)
+	return scanner
 }
@@ -0,0 +1,232 @@
+package ignoreManager
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/fileManager"
+)
+
+const (
+	HighVolume = "highvolume"
+	Ignored    = "ignored"
+)
+
+type Entry struct {
+	Domain  string    `json:"domain"`
+	Kind    string    `json:"kind"`
+	Message string    `json:"message,omitempty"`
+	AddedAt time.Time `json:"addedAt"`
+}
+
+type ignoreFile struct {
+	Entries []Entry `json:"entries"`
+}
+
+// IgnoreManager keeps high volume and ignored domains in their own data file,
+// separate from config.yaml. Writes take a lock file and re-read the data file
+// so concurrent writers never lose each other's entries.
+type IgnoreManager struct {
+	path     string
+	mu       sync.Mutex
+	loaded   bool
+	entries  map[string]Entry
+	suffixes map[string]bool
+}
+
+func New(path string) *IgnoreManager {
+	return &IgnoreManager{
+		path:    path,
+		entries: make(map[string]Entry),
+	}
+}
+
+// Returns all entries sorted by domain
+func (im *IgnoreManager) List() ([]Entry, error) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	err := im.ensureLoaded()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(im.entries))
+	for _, entry := range im.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Domain < entries[j].Domain })
+	return entries, nil
+}
+
+// Adds a domain. Adding an existing domain keeps its original timestamp.
+func (im *IgnoreManager) Add(domain string, kind string, message string) error {
+	_, err := im.Import([]Entry{{Domain: domain, Kind: kind, Message: message}})
+	return err
+}
+
+// Adds entries, returning how many were new
+func (im *IgnoreManager) Import(entries []Entry) (int, error) {
+	added := 0
+	err := im.update(func() {
+		for _, entry := range entries {
+			entry.Domain = normalizeDomain(entry.Domain)
+			if entry.Domain == "" {
+				continue
+			}
+			if _, ok := im.entries[entry.Domain]; ok {
+				continue
+			}
+			if entry.Kind == "" {
+				entry.Kind = Ignored
+			}
+			if entry.AddedAt.IsZero() {
+				entry.AddedAt = time.Now().UTC()
+			}
+			im.entries[entry.Domain] = entry
+			added++
+		}
+	})
+	return added, err
+}
+
+// Removes a domain, returning false if it was not present
+func (im *IgnoreManager) Remove(domain string) (bool, error) {
+	domain = normalizeDomain(domain)
+	removed := false
+	err := im.update(func() {
+		if _, ok := im.entries[domain]; ok {
+			delete(im.entries, domain)
+			removed = true
+		}
+	})
+	return removed, err
+}
+
+// Reports whether host or one of its parent domains is ignored
+func (im *IgnoreManager) Matches(host string) (bool, error) {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	err := im.ensureLoaded()
+	if err != nil {
+		return false, err
+	}
+
+	host = normalizeDomain(host)
+	for host != "" {
+		if im.suffixes[host] {
+			return true, nil
+		}
+		i := strings.Index(host, ".")
+		if i < 0 {
+			break
+		}
+		host = host[i+1:]
+	}
+	return false, nil
+}
+
+func (im *IgnoreManager) ensureLoaded() error {
+	if im.loaded {
+		return nil
+	}
+	return im.load()
+}
+
+func (im *IgnoreManager) load() error {
+	exists, err := fileManager.IsExists(im.path)
+	if err != nil {
+		return err
+	}
+
+	var data ignoreFile
+	if exists {
+		content, err := fileManager.ReadFile(im.path)
+		if err != nil {
+			return err
+		}
+		if len(content) > 0 {
+			err = json.Unmarshal(content, &data)
+			if err != nil {
+				return fmt.Errorf("error reading %s: %w", im.path, err)
+			}
+		}
+	}
+
+	im.entries = make(map[string]Entry)
+	for _, entry := range data.Entries {
+		im.entries[normalizeDomain(entry.Domain)] = entry
+	}
+	im.compile()
+	im.loaded = true
+	return nil
+}
+
+// Applies a change under the lock file, re-reading the data file first
+func (im *IgnoreManager) update(change func()) error {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+
+	unlock, err := fileManager.LockFile(im.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = im.load()
+	if err != nil {
+		return err
+	}
+
+	change()
+	im.compile()
+
+	data := ignoreFile{Entries: make([]Entry, 0, len(im.entries))}
+	for _, entry := range im.entries {
+		data.Entries = append(data.Entries, entry)
+	}
+	sort.Slice(data.Entries, func(i, j int) bool { return data.Entries[i].Domain < data.Entries[j].Domain })
+
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fileManager.WriteFileAtomic(im.path, content, 0644)
+}
+
+func (im *IgnoreManager) compile() {
+	im.suffixes = make(map[string]bool, len(im.entries))
+	for domain := range im.entries {
+		im.suffixes[domain] = true
+	}
+}
+
+// Reports whether a domain is a valid host name like example.com once
+// normalized. URLs and IP addresses are not domains.
+func ValidDomain(domain string) bool {
+	domain = normalizeDomain(domain)
+	if domain == "" || len(domain) > 253 || net.ParseIP(domain) != nil {
+		return false
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' && c != '_' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
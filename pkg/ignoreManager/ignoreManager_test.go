@@ -0,0 +1,100 @@
+package ignoreManager
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestMatches(t *testing.T) {
+	im := New(filepath.Join(t.TempDir(), "ignore.json"))
+	if _, err := im.Import([]Entry{{Domain: "Example.COM."}, {Domain: "ads.tracker.net"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"example.com", true},
+		{"EXAMPLE.com.", true},
+		{"www.example.com", true},
+		{"a.b.example.com", true},
+		{"notexample.com", false},
+		{"example.com.evil.org", false},
+		{"com", false},
+		{"ads.tracker.net", true},
+		{"cdn.ads.tracker.net", true},
+		{"tracker.net", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		got, err := im.Matches(test.host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("Matches(%q) = %v, want %v", test.host, got, test.want)
+		}
+	}
+}
+
+// Writers holding their own IgnoreManager over the same file, like two
+// commands running at once, keep each other's entries
+func TestConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ignore.json")
+	first, second := New(path), New(path)
+
+	// Load both before either writes, so the second only sees the first's
+	// entry by re-reading the file under the lock
+	if _, err := first.List(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.List(); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Add("first.com", Ignored, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Add("second.com", HighVolume, ""); err != nil {
+		t.Fatal(err)
+	}
+	if matched, err := second.Matches("www.first.com"); err != nil || !matched {
+		t.Errorf("second.Matches(www.first.com) = %v, %v, want true", matched, err)
+	}
+
+	const writers, each = 8, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*each)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			im := New(path)
+			for i := 0; i < each; i++ {
+				if err := im.Add(fmt.Sprintf("host%d-%d.com", w, i), Ignored, ""); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	entries, err := New(path).List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2+writers*each {
+		t.Fatalf("List() returned %d entries, want %d", len(entries), 2+writers*each)
+	}
+	removed, err := first.Remove("second.com")
+	if err != nil || !removed {
+		t.Errorf("first.Remove(second.com) = %v, %v, want true", removed, err)
+	}
+}
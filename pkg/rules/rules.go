@@ -0,0 +1,219 @@
+package rules
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/source"
+)
+
+const (
+	ActionDrop          = "drop"
+	ActionKeep          = "keep"
+	ActionReplace       = "replace"
+	ActionLowercase     = "lowercase"
+	ActionLowercasePath = "lowercasepath"
+	ActionStripQuery    = "stripquery"
+	ActionHost          = "host"
+	ActionSet           = "set"
+)
+
+// Config is a rule from config.yaml. A rule applies to the indicators matching
+// all of its expressions and its pattern, optionally only for some sources.
+type Config struct {
+	Name        string   `mapstructure:"name"`
+	Action      string   `mapstructure:"action"`
+	Sources     []string `mapstructure:"sources"`
+	Match       []string `mapstructure:"match"`
+	Pattern     string   `mapstructure:"pattern"`
+	Replacement string   `mapstructure:"replacement"`
+	Field       string   `mapstructure:"field"`
+	Value       string   `mapstructure:"value"`
+}
+
+// Trace records a rule changing or dropping an entry. Result is empty when
+// the entry was dropped.
+type Trace struct {
+	Rule     string `json:"rule"`
+	Original string `json:"original"`
+	Result   string `json:"result,omitempty"`
+}
+
+func (t Trace) Dropped() bool {
+	return t.Result == ""
+}
+
+type rule struct {
+	Config
+	sources map[string]bool
+	filter  source.Filter
+	pattern *regexp.Regexp
+}
+
+// Engine applies ordered transform and filter rules to indicators
+type Engine struct {
+	rules []rule
+}
+
+func New(configs []Config) (*Engine, error) {
+	engine := &Engine{}
+	for i, cfg := range configs {
+		if cfg.Name == "" {
+			cfg.Name = fmt.Sprintf("rule %d (%s)", i+1, cfg.Action)
+		}
+		r := rule{Config: cfg}
+
+		switch cfg.Action {
+		case ActionDrop, ActionKeep, ActionLowercase, ActionLowercasePath, ActionStripQuery, ActionHost:
+		case ActionReplace:
+			if cfg.Pattern == "" {
+				return nil, fmt.Errorf("%s: replace needs a pattern", cfg.Name)
+			}
+		case ActionSet:
+			if cfg.Field == "" {
+				return nil, fmt.Errorf("%s: set needs a field", cfg.Name)
+			}
+		default:
+			return nil, fmt.Errorf("%s: unknown action %q", cfg.Name, cfg.Action)
+		}
+
+		var err error
+		r.filter, err = source.ParseFilter(cfg.Match)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.Name, err)
+		}
+		if cfg.Pattern != "" {
+			r.pattern, err = regexp.Compile(cfg.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid pattern: %w", cfg.Name, err)
+			}
+		}
+		if len(cfg.Sources) > 0 {
+			r.sources = make(map[string]bool)
+			for _, name := range cfg.Sources {
+				r.sources[name] = true
+			}
+		}
+		engine.rules = append(engine.rules, r)
+	}
+	return engine, nil
+}
+
+// Applies every rule in order to the indicators of a source, tracing each
+// change and drop. Entries that end up with the same value are merged,
+// keeping the metadata of the first.
+func (e *Engine) Apply(sourceName string, indicators []source.Indicator) ([]source.Indicator, []Trace) {
+	if len(e.rules) == 0 {
+		return indicators, nil
+	}
+
+	var result []source.Indicator
+	var traces []Trace
+	seen := make(map[string]bool)
+
+	for _, indicator := range indicators {
+		original := indicator.Value
+		kept := true
+		for _, r := range e.rules {
+			if r.sources != nil && !r.sources[sourceName] {
+				continue
+			}
+
+			matched := r.matches(indicator)
+			if r.Action == ActionKeep {
+				kept = matched
+			} else if matched {
+				before := indicator.Value
+				indicator, kept = r.apply(indicator)
+				if kept && indicator.Value != before {
+					traces = append(traces, Trace{Rule: r.Name, Original: before, Result: indicator.Value})
+				}
+			}
+
+			if !kept {
+				traces = append(traces, Trace{Rule: r.Name, Original: original})
+				break
+			}
+		}
+		if !kept || indicator.Value == "" || seen[indicator.Value] {
+			continue
+		}
+		seen[indicator.Value] = true
+		result = append(result, indicator)
+	}
+
+	return result, traces
+}
+
+// Reports whether a rule applies to an indicator. A rule with a pattern only
+// applies to values the pattern matches.
+func (r rule) matches(indicator source.Indicator) bool {
+	if !r.filter.Match(indicator) {
+		return false
+	}
+	return r.pattern == nil || r.pattern.MatchString(indicator.Value)
+}
+
+// Applies a rule to a matching indicator, returning false when it is dropped
+func (r rule) apply(indicator source.Indicator) (source.Indicator, bool) {
+	value := indicator.Value
+	switch r.Action {
+	case ActionDrop:
+		return indicator, false
+	case ActionReplace:
+		value = r.pattern.ReplaceAllString(value, r.Replacement)
+	case ActionLowercase:
+		value = strings.ToLower(value)
+	case ActionLowercasePath:
+		value = rewriteURL(value, func(u *url.URL) {
+			u.Path = strings.ToLower(u.Path)
+			u.RawPath = ""
+		})
+	case ActionStripQuery:
+		value = rewriteURL(value, func(u *url.URL) {
+			u.RawQuery = ""
+			u.ForceQuery = false
+			u.Fragment = ""
+			u.RawFragment = ""
+		})
+	case ActionHost:
+		if u, ok := parseURL(value); ok {
+			value = strings.ToLower(u.Hostname())
+		}
+	case ActionSet:
+		metadata := make(map[string]string, len(indicator.Metadata)+1)
+		for k, v := range indicator.Metadata {
+			metadata[k] = v
+		}
+		metadata[r.Field] = r.Value
+		indicator.Metadata = metadata
+	}
+	indicator.Value = strings.TrimSpace(value)
+	return indicator, indicator.Value != ""
+}
+
+// Rewrites a URL, keeping URLs without a scheme like evil.com/path without one
+func rewriteURL(value string, rewrite func(u *url.URL)) string {
+	u, ok := parseURL(value)
+	if !ok {
+		return value
+	}
+	rewrite(u)
+	if !strings.Contains(value, "://") {
+		return strings.TrimPrefix(u.String(), "http://")
+	}
+	return u.String()
+}
+
+func parseURL(value string) (*url.URL, bool) {
+	if !strings.Contains(value, "/") {
+		return nil, false
+	}
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+	u, err := url.Parse(value)
+	return u, err == nil && u.Host != ""
+}
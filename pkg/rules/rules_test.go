@@ -0,0 +1,202 @@
+package rules
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/source"
+)
+
+func indicators(values ...string) []source.Indicator {
+	var result []source.Indicator
+	for _, value := range values {
+		result = append(result, source.Indicator{Value: value})
+	}
+	return result
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name       string
+		configs    []Config
+		source     string
+		indicators []source.Indicator
+		want       []string
+		wantID     string
+		traces     []Trace
+	}{
+		{
+			name: "rewrite before keep keeps the rewritten entry",
+			configs: []Config{
+				{Name: "strip www", Action: ActionReplace, Pattern: `^www\.`},
+				{Name: "only evil", Action: ActionKeep, Match: []string{"value ~= ^evil"}},
+			},
+			indicators: indicators("www.evil.com", "good.com"),
+			want:       []string{"evil.com"},
+			traces: []Trace{
+				{Rule: "strip www", Original: "www.evil.com", Result: "evil.com"},
+				{Rule: "only evil", Original: "good.com"},
+			},
+		},
+		{
+			name: "keep before rewrite drops the entry first",
+			configs: []Config{
+				{Name: "only evil", Action: ActionKeep, Match: []string{"value ~= ^evil"}},
+				{Name: "strip www", Action: ActionReplace, Pattern: `^www\.`},
+			},
+			indicators: indicators("www.evil.com", "evil.org"),
+			want:       []string{"evil.org"},
+			traces:     []Trace{{Rule: "only evil", Original: "www.evil.com"}},
+		},
+		{
+			name: "drop stops later rules",
+			configs: []Config{
+				{Name: "no ads", Action: ActionDrop, Pattern: `^ads\.`},
+				{Name: "lower", Action: ActionLowercase},
+			},
+			indicators: indicators("ads.example.com", "EVIL.com"),
+			want:       []string{"evil.com"},
+			traces: []Trace{
+				{Rule: "no ads", Original: "ads.example.com"},
+				{Rule: "lower", Original: "EVIL.com", Result: "evil.com"},
+			},
+		},
+		{
+			name:    "entries merged after a rewrite keep the first metadata",
+			configs: []Config{{Name: "host", Action: ActionHost}},
+			indicators: []source.Indicator{
+				{Value: "http://evil.com/a", Metadata: map[string]string{"id": "1"}},
+				{Value: "https://EVIL.com/b", Metadata: map[string]string{"id": "2"}},
+				{Value: "evil.com", Metadata: map[string]string{"id": "3"}},
+			},
+			want:   []string{"evil.com"},
+			wantID: "1",
+			traces: []Trace{
+				{Rule: "host", Original: "http://evil.com/a", Result: "evil.com"},
+				{Rule: "host", Original: "https://EVIL.com/b", Result: "evil.com"},
+			},
+		},
+		{
+			name:       "rules for other sources are skipped",
+			configs:    []Config{{Name: "drop all", Action: ActionDrop, Sources: []string{"other"}}},
+			source:     "feed",
+			indicators: indicators("evil.com"),
+			want:       []string{"evil.com"},
+		},
+		{
+			name:       "replacing with nothing drops the entry",
+			configs:    []Config{{Name: "blank", Action: ActionReplace, Pattern: `.*`}},
+			indicators: indicators("evil.com"),
+			traces:     []Trace{{Rule: "blank", Original: "evil.com"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine, err := New(test.configs)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			name := test.source
+			if name == "" {
+				name = "feed"
+			}
+
+			result, traces := engine.Apply(name, test.indicators)
+			var got []string
+			for _, indicator := range result {
+				got = append(got, indicator.Value)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Apply() = %v, want %v", got, test.want)
+			}
+			if !reflect.DeepEqual(traces, test.traces) {
+				t.Errorf("Apply() traces = %v, want %v", traces, test.traces)
+			}
+			if test.wantID != "" && result[0].Metadata["id"] != test.wantID {
+				t.Errorf("merged entry has metadata %v, want id %s", result[0].Metadata, test.wantID)
+			}
+		})
+	}
+}
+
+func TestApplySet(t *testing.T) {
+	engine, err := New([]Config{{Action: ActionSet, Field: "confidence", Value: "100", Match: []string{"value ~= evil"}}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	original := []source.Indicator{{Value: "evil.com", Metadata: map[string]string{"confidence": "10"}}, {Value: "good.com"}}
+	result, traces := engine.Apply("feed", original)
+	if len(traces) != 0 {
+		t.Errorf("Apply() traces = %v, want none", traces)
+	}
+	if got := result[0].Metadata["confidence"]; got != "100" {
+		t.Errorf("confidence = %q, want 100", got)
+	}
+	if got := original[0].Metadata["confidence"]; got != "10" {
+		t.Errorf("original metadata changed to %q", got)
+	}
+	if result[1].Metadata != nil {
+		t.Errorf("unmatched entry has metadata %v", result[1].Metadata)
+	}
+}
+
+func TestRewriteURL(t *testing.T) {
+	stripQuery := func(u *url.URL) {
+		u.RawQuery = ""
+		u.ForceQuery = false
+		u.Fragment = ""
+	}
+	lowercasePath := func(u *url.URL) {
+		u.Path = strings.ToLower(u.Path)
+		u.RawPath = ""
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		rewrite func(u *url.URL)
+		want    string
+	}{
+		{name: "without a scheme", value: "evil.com/Login?next=1#top", rewrite: stripQuery, want: "evil.com/Login"},
+		{name: "with a scheme", value: "https://evil.com/login?next=1", rewrite: stripQuery, want: "https://evil.com/login"},
+		{name: "http scheme is kept", value: "http://evil.com/a?b", rewrite: stripQuery, want: "http://evil.com/a"},
+		{name: "lowercase path without a scheme", value: "evil.com/A/B", rewrite: lowercasePath, want: "evil.com/a/b"},
+		{name: "bare domain is unchanged", value: "evil.com", rewrite: lowercasePath, want: "evil.com"},
+		{name: "path without a host is unchanged", value: "/Login", rewrite: lowercasePath, want: "/Login"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := rewriteURL(test.value, test.rewrite); got != test.want {
+				t.Errorf("rewriteURL(%q) = %q, want %q", test.value, got, test.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{name: "unknown action", config: Config{Action: "rename"}, wantErr: `unknown action "rename"`},
+		{name: "replace without a pattern", config: Config{Action: ActionReplace}, wantErr: "needs a pattern"},
+		{name: "set without a field", config: Config{Action: ActionSet}, wantErr: "needs a field"},
+		{name: "invalid pattern", config: Config{Action: ActionDrop, Pattern: "("}, wantErr: "invalid pattern"},
+		{name: "invalid match", config: Config{Action: ActionDrop, Match: []string{"confidence"}}, wantErr: "rule 1 (drop): invalid filter"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := New([]Config{test.config})
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("New() error = %v, want one containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package source
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Default cap on the decompressed size of a compressed or archived source
+const defaultMaxDecompressed = 1024 * 1024 * 1024
+
+var (
+	magicGzip  = []byte{0x1f, 0x8b}
+	magicBzip2 = []byte("BZh")
+	magicZstd  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	magicZip   = []byte("PK\x03\x04")
+	magicTar   = []byte("ustar")
+)
+
+// Compression and archive formats named by a file extension
+var extensionFormats = []struct {
+	extension string
+	format    string
+}{
+	{".gz", "gzip"},
+	{".tgz", "gzip"},
+	{".bz2", "bzip2"},
+	{".tbz2", "bzip2"},
+	{".zst", "zstd"},
+	{".tzst", "zstd"},
+	{".zip", "zip"},
+}
+
+// decompressor reads the streams inside a compressed or archived source,
+// counting the output of every decompression against a cap shared by every
+// member. Archive framing is not counted again, so the members of a tar.gz
+// count once through the gzip stream and a zip copied to a temporary file
+// only counts its members.
+type decompressor struct {
+	member    string
+	max       int64
+	remaining int64
+	matched   int
+	read      func(name string, r io.Reader) error
+}
+
+// Calls read for every stream in a source: the source itself, its
+// decompressed content, or each archive member matching the member pattern.
+// Compression is detected from magic bytes, and a .tar name marks a tar
+// archive without them. A name with a compression extension like .gz must
+// hold that format.
+func readStreams(cfg Config, name string, r io.Reader, read func(name string, r io.Reader) error) error {
+	max := cfg.MaxDecompressed
+	if max <= 0 {
+		max = defaultMaxDecompressed
+	}
+	d := &decompressor{member: cfg.Member, max: max, remaining: max, read: read}
+
+	if f, ok := r.(*os.File); ok {
+		if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+			magic := make([]byte, len(magicZip))
+			if n, _ := f.ReadAt(magic, 0); n == len(magic) && bytes.Equal(magic, magicZip) {
+				if err := checkExtension(name, "zip"); err != nil {
+					return err
+				}
+				return d.finish(name, d.readZipAt(name, f, info.Size()))
+			}
+		}
+	}
+	return d.finish(name, d.readStream(name, r))
+}
+
+// Parses every stream of a source, calling emit for each indicator as it is
+// read
+func parseStreams(cfg Config, name string, r io.Reader, emit func(Indicator) error) error {
+	return readStreams(cfg, name, r, func(member string, r io.Reader) error {
+		err := parseEach(cfg, r, emit)
+		if err != nil {
+			return fmt.Errorf("error parsing %s: %w", member, err)
+		}
+		return nil
+	})
+}
+
+func (d *decompressor) finish(name string, err error) error {
+	if err != nil {
+		return err
+	}
+	if d.matched == 0 {
+		if d.member != "" {
+			return fmt.Errorf("no member of %s matches %q", name, d.member)
+		}
+		return fmt.Errorf("%s is an empty archive", name)
+	}
+	return nil
+}
+
+func (d *decompressor) readStream(name string, r io.Reader) error {
+	br := bufio.NewReaderSize(r, 64*1024)
+	magic, _ := br.Peek(512)
+	format := magicFormat(magic)
+	if err := checkExtension(name, format); err != nil {
+		return err
+	}
+
+	switch format {
+	case "gzip":
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("error decompressing %s: %w", name, err)
+		}
+		defer gz.Close()
+		return d.readStream(trimExtension(name, ".gz", ".tgz"), d.limit(gz))
+	case "bzip2":
+		return d.readStream(trimExtension(name, ".bz2", ".tbz2"), d.limit(bzip2.NewReader(br)))
+	case "zstd":
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return fmt.Errorf("error decompressing %s: %w", name, err)
+		}
+		defer zr.Close()
+		return d.readStream(trimExtension(name, ".zst", ".tzst"), d.limit(zr))
+	case "zip":
+		return d.readZip(name, br)
+	}
+	if len(magic) >= 262 && bytes.Equal(magic[257:262], magicTar) || strings.HasSuffix(strings.ToLower(name), ".tar") {
+		return d.readTar(name, br)
+	}
+
+	d.matched++
+	return d.read(name, br)
+}
+
+func (d *decompressor) readTar(name string, r io.Reader) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", name, err)
+		}
+		if header.Typeflag != tar.TypeReg || !d.selects(header.Name) {
+			continue
+		}
+		err = d.readStream(header.Name, tr)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Zip archives need random access, so a zip that is not a file on disk, like
+// one inside a gzip stream or on standard input, is copied to a temporary file
+func (d *decompressor) readZip(name string, r io.Reader) error {
+	tmp, err := os.CreateTemp("", "umbrellasync-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", name, err)
+	}
+	return d.readZipAt(name, tmp, size)
+}
+
+func (d *decompressor) readZipAt(name string, r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", name, err)
+	}
+	for _, file := range zr.File {
+		if file.FileInfo().IsDir() || !d.selects(file.Name) {
+			continue
+		}
+		member, err := file.Open()
+		if err != nil {
+			return fmt.Errorf("error reading %s in %s: %w", file.Name, name, err)
+		}
+		err = d.readStream(file.Name, d.limit(member))
+		member.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the compression or archive format of a stream from its magic bytes,
+// or "" for anything else
+func magicFormat(magic []byte) string {
+	switch {
+	case bytes.HasPrefix(magic, magicGzip):
+		return "gzip"
+	case bytes.HasPrefix(magic, magicBzip2):
+		return "bzip2"
+	case bytes.HasPrefix(magic, magicZstd):
+		return "zstd"
+	case bytes.HasPrefix(magic, magicZip):
+		return "zip"
+	}
+	return ""
+}
+
+// Fails when the extension of a name promises a format the content is not in,
+// like a .gz file that is not gzip compressed. The query of a URL is ignored.
+func checkExtension(name string, format string) error {
+	lower := strings.ToLower(name)
+	if strings.Contains(lower, "://") {
+		lower, _, _ = strings.Cut(lower, "?")
+	}
+	for _, e := range extensionFormats {
+		if !strings.HasSuffix(lower, e.extension) || e.format == format {
+			continue
+		}
+		if format == "" {
+			return fmt.Errorf("%s has a %s extension but is not in %s format", name, e.extension, e.format)
+		}
+		return fmt.Errorf("%s has a %s extension but is in %s format", name, e.extension, format)
+	}
+	return nil
+}
+
+// Reports whether an archive member is read. Without a pattern every member
+// but hidden files is read, otherwise the pattern must match the member's
+// path or base name.
+func (d *decompressor) selects(member string) bool {
+	if d.member == "" {
+		return !strings.HasPrefix(path.Base(member), ".")
+	}
+	if ok, _ := path.Match(d.member, member); ok {
+		return true
+	}
+	ok, _ := path.Match(d.member, path.Base(member))
+	return ok
+}
+
+// Wraps a decompressed stream so reading past the cap fails the source
+// instead of filling the disk or memory
+func (d *decompressor) limit(r io.Reader) io.Reader {
+	return &capReader{r: r, d: d}
+}
+
+type capReader struct {
+	r io.Reader
+	d *decompressor
+}
+
+func (c *capReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.d.remaining -= int64(n)
+	if c.d.remaining < 0 {
+		return n, fmt.Errorf("decompressed size exceeds the maximum of %d bytes", c.d.max)
+	}
+	return n, err
+}
+
+// Trims a compression extension from a name, so feed.tar.gz and feed.tgz
+// become feed.tar
+func trimExtension(name string, extension, tarExtension string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, extension):
+		return name[:len(name)-len(extension)]
+	case strings.HasSuffix(lower, tarExtension):
+		return name[:len(name)-len(tarExtension)] + ".tar"
+	}
+	return name
+}
@@ -0,0 +1,177 @@
+package source
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type member struct {
+	name    string
+	content string
+}
+
+func gzipped(t *testing.T, content []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(content)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zstdCompressed(t *testing.T, content []byte) []byte {
+	var buf bytes.Buffer
+	w, err := zstd.NewWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(content)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarred(t *testing.T, members ...member) []byte {
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, m := range members {
+		w.WriteHeader(&tar.Header{Name: m.name, Mode: 0o644, Size: int64(len(m.content)), Typeflag: tar.TypeReg})
+		w.Write([]byte(m.content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipped(t *testing.T, members ...member) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, m := range members {
+		f, err := w.Create(m.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(m.content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseStreams(t *testing.T) {
+	// list is 1000 bytes, the cases with a cap around that size check members count once
+	list := strings.Repeat("evil.com\n", 100) + strings.Repeat("x", 99) + "\n"
+	members := []member{{"feed/a.txt", list}, {"feed/b.txt", "bad.org\n"}, {"feed/.hidden", "hidden.com\n"}}
+
+	tests := []struct {
+		name    string
+		file    string
+		content []byte
+		member  string
+		max     int64
+		want    string
+		wantErr string
+	}{
+		{name: "plain", file: "feed.txt", content: []byte("evil.com\n"), want: "evil.com"},
+		{name: "gzip", file: "feed.txt.gz", content: gzipped(t, []byte("evil.com\n")), want: "evil.com"},
+		{name: "zstd", file: "feed.zst", content: zstdCompressed(t, []byte("evil.com\n")), want: "evil.com"},
+		{name: "gzip without an extension", file: "feed", content: gzipped(t, []byte("evil.com\n")), want: "evil.com"},
+		{name: "tar.gz", file: "feed.tgz", content: gzipped(t, tarred(t, members...)), want: "evil.com,x" + strings.Repeat("x", 98) + ",bad.org"},
+		{name: "zip", file: "feed.zip", content: zipped(t, members...), want: "evil.com,x" + strings.Repeat("x", 98) + ",bad.org"},
+		{name: "member pattern", file: "feed.zip", content: zipped(t, members...), member: "b.txt", want: "bad.org"},
+		{name: "no matching member", file: "feed.zip", content: zipped(t, members...), member: "c.txt", wantErr: `no member of feed.zip matches "c.txt"`},
+		{name: "tar.gz members count once", file: "feed.tar.gz", content: gzipped(t, tarred(t, members[0])), max: 3000, want: "evil.com,x" + strings.Repeat("x", 98)},
+		{name: "zip members count once", file: "feed.zip", content: zipped(t, members[0]), max: 1000, want: "evil.com,x" + strings.Repeat("x", 98)},
+		{name: "over the cap", file: "feed.gz", content: gzipped(t, []byte(list)), max: 999, wantErr: "exceeds the maximum of 999 bytes"},
+		{name: "gz extension on plain text", file: "feed.gz", content: []byte("evil.com\n"), wantErr: "feed.gz has a .gz extension but is not in gzip format"},
+		{name: "zip extension on gzip", file: "feed.zip", content: gzipped(t, []byte("evil.com\n")), wantErr: "feed.zip has a .zip extension but is in gzip format"},
+		{name: "URL query is ignored", file: "https://example.com/feed.txt?format=.gz", content: []byte("evil.com\n"), want: "evil.com"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := Config{Member: test.member, MaxDecompressed: test.max}
+
+			// Streamed, like a download or standard input
+			indicators, err := parseAllStreams(cfg, test.file, bytes.NewReader(test.content))
+			checkParsed(t, "streamed", indicators, err, test.want, test.wantErr)
+
+			// From a file on disk, which zip archives are read from directly
+			if strings.Contains(test.file, "://") {
+				return
+			}
+			path := filepath.Join(t.TempDir(), test.file)
+			if err := os.WriteFile(path, test.content, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			indicators, err = parseAllStreams(cfg, test.file, f)
+			checkParsed(t, "file", indicators, err, test.want, test.wantErr)
+		})
+	}
+}
+
+func parseAllStreams(cfg Config, name string, r io.Reader) ([]Indicator, error) {
+	return collect(func(emit func(Indicator) error) error {
+		return parseStreams(cfg, name, r, unique(emit))
+	})
+}
+
+func checkParsed(t *testing.T, how string, indicators []Indicator, err error, want string, wantErr string) {
+	t.Helper()
+	if wantErr != "" {
+		if err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("%s: parseStreams() error = %v, want one containing %q", how, err, wantErr)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("%s: parseStreams() error = %v", how, err)
+	}
+	if got := values(indicators); got != want {
+		t.Errorf("%s: parseStreams() = %q, want %q", how, got, want)
+	}
+}
+
+func BenchmarkParseStreams(b *testing.B) {
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	if _, err := io.Copy(w, &generatedLines{n: benchmarkLines, line: domainLine}); err != nil {
+		b.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		b.Fatal(err)
+	}
+
+	inputs := []struct {
+		name  string
+		input func() io.Reader
+	}{
+		{"text", func() io.Reader { return &generatedLines{n: benchmarkLines, line: domainLine} }},
+		{"gzip", func() io.Reader { return bytes.NewReader(compressed.Bytes()) }},
+	}
+	for _, input := range inputs {
+		b.Run(input.name, func(b *testing.B) {
+			benchmarkParse(b, input.input, func(r io.Reader) ([]Indicator, error) {
+				return parseAllStreams(Config{}, "feed", r)
+			})
+		})
+	}
+}
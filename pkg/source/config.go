@@ -0,0 +1,159 @@
+package source
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TypeFile   = "file"
+	TypeHTTP   = "http"
+	TypeTAXII  = "taxii"
+	TypeMISP   = "misp"
+	TypePlugin = "plugin"
+)
+
+// Config describes where a source is read from and how it is parsed. Sources
+// are listed under "sources" in config.yaml, entries under "files" are file
+// sources in the default text format.
+type Config struct {
+	Name string `mapstructure:"name"`
+	Type string `mapstructure:"type"`
+	// Path is a file, a directory, a glob like /intel/daily/*.txt or - for
+	// standard input. The files of a directory or glob are merged into one
+	// source unless PerFile is set.
+	Path    string            `mapstructure:"path"`
+	PerFile bool              `mapstructure:"perfile"`
+	URL     string            `mapstructure:"url"`
+	Format  string            `mapstructure:"format"`
+	Headers map[string]string `mapstructure:"headers"`
+	Timeout int               `mapstructure:"timeout"`
+	MaxSize int64             `mapstructure:"maxsize"`
+	Retries int               `mapstructure:"retries"`
+
+	// File and HTTP sources compressed with gzip, bzip2 or zstd, or archived
+	// as zip or tar, are decompressed as they are read. A .gz, .bz2, .zst or
+	// .zip file must be in that format. Member is a pattern like *.txt
+	// selecting archive members, all are read when it is empty.
+	// MaxDecompressed caps the bytes every decompression outputs, 1GB by default.
+	Member          string `mapstructure:"member"`
+	MaxDecompressed int64  `mapstructure:"maxdecompressed"`
+
+	// Longest line of a text, JSON lines, extract or plugin source in bytes,
+	// 1MB by default. A longer line fails the source.
+	MaxLineLength int `mapstructure:"maxlinelength"`
+
+	// TAXII collections are read from the API root found through discovery
+	// at URL unless apiroot is set. Collection is an ID or a title.
+	APIRoot    string `mapstructure:"apiroot"`
+	Collection string `mapstructure:"collection"`
+	Username   string `mapstructure:"username"`
+	Password   string `mapstructure:"password"`
+	Token      string `mapstructure:"token"`
+
+	// MISP attributes are read from the REST search endpoint at URL, or from
+	// a JSON export when the format is misp. ThreatLevel keeps events with a
+	// threat_level_id at or below it, Since is a date or a number of days like 30d.
+	APIKey      string   `mapstructure:"apikey"`
+	Tags        []string `mapstructure:"tags"`
+	ToIDs       *bool    `mapstructure:"toids"`
+	ThreatLevel int      `mapstructure:"threatlevel"`
+	Since       string   `mapstructure:"since"`
+
+	// CSV sources read the indicator from Column, a header name or a 0-based
+	// index. JSON and JSON lines sources read records from Records (the whole
+	// document when empty) and the indicator from the Field path in each
+	// record. Metadata maps metadata names to columns or record paths and
+	// Filters are expressions like "confidence >= 80" on the same.
+	Delimiter string            `mapstructure:"delimiter"`
+	Header    *bool             `mapstructure:"header"`
+	Column    string            `mapstructure:"column"`
+	Records   string            `mapstructure:"records"`
+	Field     string            `mapstructure:"field"`
+	Metadata  map[string]string `mapstructure:"metadata"`
+	Filters   []string          `mapstructure:"filters"`
+
+	// Plugin sources run Command with Args and Env added to the environment,
+	// reading JSON lines from its stdout. Env entries are KEY=VALUE strings,
+	// a list since config keys are lowercased and variable names are not.
+	Command string   `mapstructure:"command"`
+	Args    []string `mapstructure:"args"`
+	Env     []string `mapstructure:"env"`
+
+	// Filters applied to the metadata of parsed indicators
+	MinConfidence int      `mapstructure:"minconfidence"`
+	Labels        []string `mapstructure:"labels"`
+
+	// Default time to live of indicators without an expires date, counted
+	// from the first time the indicator was seen. For example 30d or 12h.
+	TTL string `mapstructure:"ttl"`
+}
+
+// Fills in the type and name when they are not configured
+func (c Config) WithDefaults() Config {
+	if c.Type == "" {
+		c.Type = TypeFile
+		if c.URL != "" {
+			c.Type = TypeHTTP
+		}
+	}
+
+	if c.Name == "" {
+		switch {
+		case c.Path == StdinPath:
+			c.Name = "stdin"
+		case c.Path != "" && isGlob(c.Path):
+			// Named after the last directory without wildcards
+			c.Name = filepath.Base(globRoot(c.Path))
+		case c.Path != "":
+			c.Name = filepath.Base(c.Path)
+		case c.Type == TypeTAXII && c.Collection != "":
+			c.Name = c.Collection
+		case c.Type == TypePlugin && c.Command != "":
+			c.Name = filepath.Base(c.Command)
+		case c.URL != "":
+			c.Name = c.URL
+			if u, err := url.Parse(c.URL); err == nil {
+				c.Name = u.Host
+				if base := path.Base(u.Path); base != "/" && base != "." {
+					c.Name = base
+				}
+			}
+		}
+	}
+
+	c.Format = strings.ToLower(c.Format)
+	return c
+}
+
+// Returns the path, URL or command the source is read from
+func (c Config) Location() string {
+	if c.Type == TypePlugin {
+		return strings.TrimSpace(c.Command + " " + strings.Join(c.Args, " "))
+	}
+	if c.URL != "" {
+		return c.URL
+	}
+	return c.Path
+}
+
+// Parses a Go duration, also accepting a number of days like 30d
+func ParseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q, expected days like 30d or a duration like 12h", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,134 @@
+package source
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Parses a CSV feed, reading the indicator from the configured column. Rows
+// failing any filter are skipped.
+func ParseCSV(cfg Config, r io.Reader) ([]Indicator, error) {
+	return collect(func(emit func(Indicator) error) error {
+		return parseCSV(cfg, r, unique(emit))
+	})
+}
+
+func parseCSV(cfg Config, r io.Reader, emit func(Indicator) error) error {
+	delimiter, err := csvDelimiter(cfg.Delimiter)
+	if err != nil {
+		return err
+	}
+	filters, err := parseExpressions(cfg.Filters)
+	if err != nil {
+		return err
+	}
+
+	reader := csv.NewReader(r)
+	reader.Comma = delimiter
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	column := cfg.Column
+	if column == "" {
+		column = "0"
+	}
+	_, numeric := strconv.Atoi(column)
+	hasHeader := numeric != nil
+	if cfg.Header != nil {
+		hasHeader = *cfg.Header
+	}
+
+	columns := make(map[string]int)
+	if hasHeader {
+		header, err := reader.Read()
+		if err != nil {
+			return fmt.Errorf("error reading CSV header: %w", err)
+		}
+		for i, name := range header {
+			columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+		}
+	}
+
+	// Columns are referenced by header name or by index
+	columnIndex := func(name string) (int, bool) {
+		if i, ok := columns[strings.ToLower(name)]; ok {
+			return i, true
+		}
+		i, err := strconv.Atoi(name)
+		return i, err == nil && i >= 0
+	}
+
+	indexColumn, ok := columnIndex(column)
+	if !ok {
+		return fmt.Errorf("CSV column %q not found", column)
+	}
+
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading CSV: %w", err)
+		}
+
+		get := func(name string) (string, bool) {
+			i, ok := columnIndex(name)
+			if !ok || i >= len(row) {
+				return "", false
+			}
+			return strings.TrimSpace(row[i]), true
+		}
+
+		if indexColumn >= len(row) || !matchAll(filters, get) {
+			continue
+		}
+
+		value := normalizeValue(row[indexColumn])
+		if value == "" {
+			continue
+		}
+		err = emit(Indicator{Value: value, Metadata: selectMetadata(cfg.Metadata, get)})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func csvDelimiter(delimiter string) (rune, error) {
+	switch delimiter {
+	case "":
+		return ',', nil
+	case `\t`, "tab":
+		return '\t', nil
+	}
+	if utf8.RuneCountInString(delimiter) != 1 {
+		return 0, fmt.Errorf("CSV delimiter must be a single character, got %q", delimiter)
+	}
+	r, _ := utf8.DecodeRuneInString(delimiter)
+	return r, nil
+}
+
+// Resolves the configured metadata fields
+func selectMetadata(fields map[string]string, get func(string) (string, bool)) map[string]string {
+	if len(fields) == 0 {
+		return nil
+	}
+	metadata := make(map[string]string)
+	for name, field := range fields {
+		if value, ok := get(field); ok && value != "" {
+			metadata[strings.ToLower(name)] = value
+		}
+	}
+	return metadata
+}
@@ -0,0 +1,99 @@
+package source
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var expressionPattern = regexp.MustCompile(`^\s*(\S+)\s*(==|!=|>=|<=|~=|>|<)\s*(.*?)\s*$`)
+
+// expression compares a field against a value. Numbers are compared
+// numerically, everything else as case insensitive strings, and ~= matches a
+// regular expression.
+type expression struct {
+	Field    string
+	Operator string
+	Value    string
+	regexp   *regexp.Regexp
+}
+
+func parseExpression(s string) (expression, error) {
+	match := expressionPattern.FindStringSubmatch(s)
+	if match == nil {
+		return expression{}, fmt.Errorf("invalid filter %q, expected a field, an operator and a value", s)
+	}
+
+	e := expression{Field: match[1], Operator: match[2], Value: strings.Trim(match[3], `"'`)}
+	if e.Operator == "~=" {
+		re, err := regexp.Compile(e.Value)
+		if err != nil {
+			return expression{}, fmt.Errorf("invalid filter %q: %w", s, err)
+		}
+		e.regexp = re
+	}
+	return e, nil
+}
+
+func parseExpressions(filters []string) ([]expression, error) {
+	var expressions []expression
+	for _, filter := range filters {
+		e, err := parseExpression(filter)
+		if err != nil {
+			return nil, err
+		}
+		expressions = append(expressions, e)
+	}
+	return expressions, nil
+}
+
+// Reports whether the field value satisfies the expression. Missing fields never match.
+func (e expression) Match(value string, ok bool) bool {
+	if !ok {
+		return false
+	}
+	if e.Operator == "~=" {
+		return e.regexp.MatchString(value)
+	}
+
+	a, errA := strconv.ParseFloat(value, 64)
+	b, errB := strconv.ParseFloat(e.Value, 64)
+	var cmp int
+	if errA == nil && errB == nil {
+		switch {
+		case a < b:
+			cmp = -1
+		case a > b:
+			cmp = 1
+		}
+	} else {
+		cmp = strings.Compare(strings.ToLower(value), strings.ToLower(e.Value))
+	}
+
+	switch e.Operator {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">=":
+		return cmp >= 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case "<":
+		return cmp < 0
+	}
+	return false
+}
+
+// Reports whether every expression matches, looking fields up with get
+func matchAll(expressions []expression, get func(field string) (string, bool)) bool {
+	for _, e := range expressions {
+		if !e.Match(get(e.Field)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,139 @@
+package source
+
+import (
+	"io"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/fileManager"
+	"golang.org/x/net/publicsuffix"
+)
+
+var (
+	defangReplacer = strings.NewReplacer(
+		"[.]", ".", "(.)", ".", "{.}", ".", "[dot]", ".", "(dot)", ".", "{dot}", ".",
+		"[:]", ":", "[://]", "://", "[/]", "/",
+		"hxxps://", "https://", "hxxp://", "http://", "hXXps://", "https://", "hXXp://", "http://",
+		"fxp://", "ftp://",
+	)
+	extractURLPattern    = regexp.MustCompile(`(?i)\b(?:https?|ftp)://[^\s<>"'` + "`" + `]+`)
+	extractEmailPattern  = regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@(?:[a-z0-9-]+\.)+[a-z]{2,63}\b`)
+	extractIPPattern     = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	extractDomainPattern = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z][a-z0-9-]{0,61}[a-z0-9]\b`)
+	extractVersionPrefix = regexp.MustCompile(`(?i)(?:\bv|\bversion\s*|\bbuild\s*|\brelease\s*)$`)
+)
+
+// File extensions that are also TLDs, which mostly show up in reports as
+// file names rather than domains
+var fileExtensions = map[string]bool{
+	"zip": true, "mov": true, "exe": true, "dll": true, "doc": true, "docx": true,
+	"xls": true, "xlsx": true, "pdf": true, "txt": true, "js": true, "py": true,
+	"sh": true, "bat": true, "ps1": true, "vbs": true, "jar": true, "rar": true,
+	"iso": true, "img": true, "lnk": true, "hta": true, "tmp": true, "log": true,
+	"dat": true, "bin": true, "msi": true, "md": true, "json": true, "xml": true,
+	"php": true, "aspx": true, "html": true, "htm": true, "png": true, "jpg": true,
+	"gif": true, "cfg": true, "ini": true, "sys": true, "scr": true, "cpl": true,
+}
+
+// Extracts domains, URLs and IPv4 addresses from unstructured text such as
+// incident write-ups. Defanged notation like hxxp:// and evil[.]com is
+// refanged. Email addresses, version strings and file names with TLD-like
+// extensions are ignored.
+func ParseExtract(cfg Config, r io.Reader) ([]Indicator, error) {
+	return collect(func(emit func(Indicator) error) error {
+		return parseExtract(cfg, r, unique(emit))
+	})
+}
+
+func parseExtract(cfg Config, r io.Reader, emit func(Indicator) error) error {
+	var err error
+	add := func(value string, kind string, line int) {
+		value = normalizeValue(value)
+		if value == "" || err != nil {
+			return
+		}
+		// Copied so the indicator does not keep the whole line in memory
+		err = emit(Indicator{
+			Value:    strings.Clone(value),
+			Metadata: map[string]string{"type": kind, "line": strconv.Itoa(line)},
+		})
+	}
+
+	scanner := fileManager.NewLineScanner(r, cfg.MaxLineLength)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		text := defangReplacer.Replace(scanner.Text())
+
+		// Each pass blanks out its matches so later passes do not pick
+		// up the host of a URL or the domain of an email address
+		text = extractURLPattern.ReplaceAllStringFunc(text, func(match string) string {
+			match = strings.TrimRight(match, ".,;:!?)]}>'\"")
+			if u, err := url.Parse(match); err == nil && isExtractableHost(u.Hostname()) {
+				add(match, "url", lineNumber)
+			}
+			return " "
+		})
+		text = extractEmailPattern.ReplaceAllString(text, " ")
+
+		for _, loc := range extractIPPattern.FindAllStringIndex(text, -1) {
+			ip := text[loc[0]:loc[1]]
+			if net.ParseIP(ip) == nil || extractVersionPrefix.MatchString(text[:loc[0]]) || isDottedContinuation(text, loc) {
+				continue
+			}
+			add(ip, "ip", lineNumber)
+		}
+		text = extractIPPattern.ReplaceAllString(text, " ")
+
+		for _, loc := range extractDomainPattern.FindAllStringIndex(text, -1) {
+			domain := text[loc[0]:loc[1]]
+			if isDottedContinuation(text, loc) || !isExtractableHost(domain) {
+				continue
+			}
+			add(domain, "domain", lineNumber)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return scanError(err, cfg.MaxLineLength)
+	}
+	return nil
+}
+
+// Reports whether a host has a known public suffix and does not look like a file name
+func isExtractableHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if net.ParseIP(host) != nil {
+		return true
+	}
+
+	suffix, icann := publicsuffix.PublicSuffix(host)
+	if suffix == host || (!icann && !strings.Contains(suffix, ".")) {
+		return false
+	}
+
+	labels := strings.Split(host, ".")
+	if len(labels) == 2 && fileExtensions[labels[1]] {
+		return false
+	}
+	return true
+}
+
+// Reports whether a match is part of a longer dotted token, such as a
+// version string like 1.2.3.4.5 or a path like C:\tools\setup.exe.bak
+func isDottedContinuation(text string, loc []int) bool {
+	if loc[0] > 0 && (text[loc[0]-1] == '.' || text[loc[0]-1] == '\\' || text[loc[0]-1] == '_') {
+		return true
+	}
+	if loc[1] < len(text)-1 && text[loc[1]] == '.' && isAlphanumeric(text[loc[1]+1]) {
+		return true
+	}
+	return loc[1] < len(text) && (text[loc[1]] == '_' || text[loc[1]] == '\\')
+}
+
+func isAlphanumeric(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
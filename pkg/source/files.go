@@ -0,0 +1,156 @@
+package source
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/fileManager"
+)
+
+// Path of a file source read from standard input
+const StdinPath = "-"
+
+func isGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// Returns the last directory of a glob without wildcards
+func globRoot(path string) string {
+	dir := filepath.Dir(path)
+	for isGlob(dir) {
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+// Returns the files a file source reads. A directory is every regular,
+// non-hidden file in it and a glob is every regular file it matches. Neither
+// may be empty, so a missing drop folder fails the source instead of emptying
+// its lists.
+func (c Config) Files() ([]string, error) {
+	if c.Path == StdinPath {
+		return []string{StdinPath}, nil
+	}
+
+	var files []string
+	if isGlob(c.Path) {
+		matches, err := filepath.Glob(c.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %s: %w", c.Path, err)
+		}
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && info.Mode().IsRegular() {
+				files = append(files, match)
+			}
+		}
+	} else {
+		info, err := os.Stat(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return []string{c.Path}, nil
+		}
+
+		infos, err := fileManager.ReadFolder(c.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			if info.Mode().IsRegular() && !strings.HasPrefix(info.Name(), ".") {
+				files = append(files, filepath.Join(c.Path, info.Name()))
+			}
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files found at %s", c.Path)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Expands a directory or glob source with perfile set into a source per
+// file, each synced to its own lists and named after the path of the file
+// below the directory or the glob's last directory without wildcards. Other
+// sources are returned as they are.
+func Expand(cfg Config) ([]Config, error) {
+	cfg = cfg.WithDefaults()
+	if cfg.Type != TypeFile || !cfg.PerFile {
+		return []Config{cfg}, nil
+	}
+
+	files, err := cfg.Files()
+	if err != nil {
+		return nil, fmt.Errorf("error reading source %s: %w", cfg.Name, err)
+	}
+
+	root := cfg.Path
+	if isGlob(cfg.Path) {
+		root = globRoot(cfg.Path)
+	}
+
+	configs := make([]Config, len(files))
+	for i, file := range files {
+		// A perfile source reading a single file is named after the file
+		name, err := filepath.Rel(root, file)
+		if err != nil || name == "." || strings.HasPrefix(name, "..") {
+			name = filepath.Base(file)
+		}
+		configs[i] = cfg
+		configs[i].Path = file
+		configs[i].Name = filepath.ToSlash(name)
+		configs[i].PerFile = false
+	}
+	return configs, nil
+}
+
+// Parses every file of a file source in turn, calling emit for each indicator
+func (l *Loader) loadFiles(cfg Config, emit func(Indicator) error) error {
+	files, err := cfg.Files()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		count, err := parseFile(cfg, file, emit)
+		if err != nil {
+			return err
+		}
+		if len(files) > 1 {
+			l.Logger.Debug("Read ", count, " indicators from ", file)
+		}
+	}
+	return nil
+}
+
+// Parses a file, returning how many indicators it listed
+func parseFile(cfg Config, file string, emit func(Indicator) error) (int, error) {
+	var r io.ReadCloser = io.NopCloser(os.Stdin)
+	if file != StdinPath {
+		f, err := os.Open(file)
+		if err != nil {
+			return 0, err
+		}
+		r = f
+	}
+	defer r.Close()
+
+	count := 0
+	err := parseStreams(cfg, file, r, func(indicator Indicator) error {
+		count++
+		return emit(indicator)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error reading %s: %w", file, err)
+	}
+	// An empty pipe is more likely a failed command than an empty feed
+	if file == StdinPath && count == 0 {
+		return 0, fmt.Errorf("no indicators read from standard input")
+	}
+	return count, nil
+}
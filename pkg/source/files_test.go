@@ -0,0 +1,65 @@
+package source
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{"a/list.txt", "b/list.txt", "b/other.txt", "top.txt"} {
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("evil.com\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{name: "directory", cfg: Config{Path: filepath.Join(dir, "b"), PerFile: true}, want: []string{"list.txt", "other.txt"}},
+		{name: "glob across directories", cfg: Config{Path: filepath.Join(dir, "*", "list.txt"), PerFile: true}, want: []string{"a/list.txt", "b/list.txt"}},
+		{name: "single file", cfg: Config{Path: filepath.Join(dir, "top.txt"), PerFile: true}, want: []string{"top.txt"}},
+		{name: "without perfile", cfg: Config{Name: "feeds", Path: filepath.Join(dir, "*", "list.txt")}, want: []string{"feeds"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			configs, err := Expand(test.cfg)
+			if err != nil {
+				t.Fatalf("Expand() error = %v", err)
+			}
+			var names []string
+			for _, cfg := range configs {
+				names = append(names, cfg.Name)
+			}
+			if !reflect.DeepEqual(names, test.want) {
+				t.Errorf("Expand() names = %v, want %v", names, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadEmptyStdin(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+
+	_, err = newTestLoader(t).Load(Config{Path: StdinPath})
+	if err == nil || !strings.Contains(err.Error(), "no indicators") {
+		t.Errorf("Load() error = %v, want one for no indicators", err)
+	}
+}
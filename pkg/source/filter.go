@@ -0,0 +1,62 @@
+package source
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Wraps emit to drop indicators below the configured confidence or without
+// any of the configured labels. Indicators without a confidence are kept.
+func filterIndicators(cfg Config, emit func(Indicator) error) func(Indicator) error {
+	if cfg.MinConfidence <= 0 && len(cfg.Labels) == 0 {
+		return emit
+	}
+
+	return func(indicator Indicator) error {
+		if cfg.MinConfidence > 0 {
+			if confidence, err := strconv.Atoi(indicator.Metadata["confidence"]); err == nil && confidence < cfg.MinConfidence {
+				return nil
+			}
+		}
+		if len(cfg.Labels) > 0 && !hasLabel(indicator, cfg.Labels) {
+			return nil
+		}
+		return emit(indicator)
+	}
+}
+
+func hasLabel(indicator Indicator, labels []string) bool {
+	if indicator.Metadata["labels"] == "" {
+		return false
+	}
+	return containsAny(strings.Split(indicator.Metadata["labels"], ","), labels)
+}
+
+// Filter holds expressions like "confidence >= 80" that an indicator must all
+// satisfy. Fields are looked up in the metadata, and "value" is the indicator itself.
+type Filter struct {
+	expressions []expression
+}
+
+func ParseFilter(filters []string) (Filter, error) {
+	expressions, err := parseExpressions(filters)
+	return Filter{expressions: expressions}, err
+}
+
+func (f Filter) Match(indicator Indicator) bool {
+	return matchAll(f.expressions, indicator.Field)
+}
+
+// Returns a metadata field, or the value itself for the "value" field
+func (i Indicator) Field(name string) (string, bool) {
+	if name == "value" {
+		return i.Value, true
+	}
+	value, ok := i.Metadata[name]
+	return value, ok
+}
+
+// Reports whether the indicator has any of the labels
+func (i Indicator) HasLabel(labels []string) bool {
+	return hasLabel(i, labels)
+}
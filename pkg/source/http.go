@@ -0,0 +1,156 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/fileManager"
+)
+
+const (
+	defaultHTTPTimeout = 60
+	defaultHTTPMaxSize = 100 * 1024 * 1024
+	defaultHTTPRetries = 3
+)
+
+// httpState is kept next to the last good copy of a feed so the next fetch
+// can be conditional
+type httpState struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+}
+
+// Downloads a feed over HTTP(S). Unchanged feeds are served from the last
+// good copy, and a failed download falls back to the last good copy instead
+// of looking like an empty feed.
+func (l *Loader) openHTTP(cfg Config) (io.ReadCloser, error) {
+	bodyPath, statePath := l.httpCachePaths(cfg.URL)
+
+	var state httpState
+	hasCopy, err := fileManager.IsExists(bodyPath)
+	if err != nil {
+		return nil, err
+	}
+	if hasCopy {
+		if content, err := fileManager.ReadFile(statePath); err == nil {
+			json.Unmarshal(content, &state)
+		}
+	}
+
+	retries := cfg.Retries
+	if retries <= 0 {
+		retries = defaultHTTPRetries
+	}
+
+	var fetchErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			backoff := time.Duration(1<<(attempt-1)) * time.Second
+			l.Logger.Debug("Retrying ", cfg.URL, " in ", backoff)
+			time.Sleep(backoff)
+		}
+
+		var retry bool
+		retry, fetchErr = l.fetchHTTP(cfg, state, bodyPath, statePath)
+		if fetchErr == nil {
+			return os.Open(bodyPath)
+		}
+		l.Logger.Warn("Error fetching ", cfg.URL, ": ", fetchErr)
+		if !retry {
+			break
+		}
+	}
+
+	if hasCopy {
+		l.Logger.Warn("Using last good copy of ", cfg.URL, " from ", state.FetchedAt.Format(time.RFC3339))
+		return os.Open(bodyPath)
+	}
+	return nil, fetchErr
+}
+
+// Performs a single conditional download. Returns whether a failure is worth retrying.
+func (l *Loader) fetchHTTP(cfg Config, state httpState, bodyPath string, statePath string) (bool, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	maxSize := cfg.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultHTTPMaxSize
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", cfg.URL, nil)
+	if err != nil {
+		return false, err
+	}
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	if state.URL == cfg.URL {
+		if state.ETag != "" {
+			req.Header.Set("If-None-Match", state.ETag)
+		}
+		if state.LastModified != "" {
+			req.Header.Set("If-Modified-Since", state.LastModified)
+		}
+	}
+
+	resp, err := l.HTTPClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		l.Logger.Info(cfg.URL, " not modified since ", state.FetchedAt.Format(time.RFC3339))
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+		return retry, fmt.Errorf("non-OK HTTP status: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return true, err
+	}
+	if int64(len(body)) > maxSize {
+		return false, fmt.Errorf("response larger than %d bytes", maxSize)
+	}
+
+	err = fileManager.WriteFileAtomic(bodyPath, body, 0644)
+	if err != nil {
+		return false, err
+	}
+
+	state = httpState{
+		URL:          cfg.URL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now().UTC(),
+	}
+	content, err := json.Marshal(state)
+	if err != nil {
+		return false, err
+	}
+	return false, fileManager.WriteFileAtomic(statePath, content, 0644)
+}
+
+func (l *Loader) httpCachePaths(url string) (string, string) {
+	sum := sha256.Sum256([]byte(url))
+	name := hex.EncodeToString(sum[:8])
+	return filepath.Join(l.DataDir, name+".body"), filepath.Join(l.DataDir, name+".json")
+}
@@ -0,0 +1,118 @@
+package source
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testLogger struct{}
+
+func (testLogger) Debug(args ...interface{}) {}
+func (testLogger) Info(args ...interface{})  {}
+func (testLogger) Warn(args ...interface{})  {}
+func (testLogger) Error(args ...interface{}) {}
+
+func newTestLoader(t *testing.T) *Loader {
+	return NewLoader(t.TempDir(), testLogger{})
+}
+
+func values(indicators []Indicator) string {
+	return strings.Join(Values(indicators), ",")
+}
+
+func TestLoadHTTP(t *testing.T) {
+	// Each test serves its responses in order, the first one seeding the
+	// last good copy
+	type response struct {
+		status int
+		body   string
+	}
+	tests := []struct {
+		name      string
+		responses []response
+		maxSize   int64
+		want      string
+		wantErr   bool
+		requests  int
+	}{
+		{
+			name:      "fetches and parses",
+			responses: []response{{http.StatusOK, "evil.com\nbad.org\n"}},
+			want:      "evil.com,bad.org",
+			requests:  1,
+		},
+		{
+			name:      "not modified uses the last good copy",
+			responses: []response{{http.StatusOK, "evil.com\n"}, {http.StatusNotModified, ""}},
+			want:      "evil.com",
+			requests:  2,
+		},
+		{
+			name:      "server error falls back to the last good copy after retrying",
+			responses: []response{{http.StatusOK, "evil.com\n"}, {http.StatusInternalServerError, ""}, {http.StatusInternalServerError, ""}},
+			want:      "evil.com",
+			requests:  3,
+		},
+		{
+			name:      "client error is not retried",
+			responses: []response{{http.StatusOK, "evil.com\n"}, {http.StatusNotFound, ""}},
+			want:      "evil.com",
+			requests:  2,
+		},
+		{
+			name:      "failure without a copy is an error",
+			responses: []response{{http.StatusNotFound, ""}},
+			wantErr:   true,
+			requests:  1,
+		},
+		{
+			name:      "oversized response is an error",
+			responses: []response{{http.StatusOK, strings.Repeat("a.com\n", 100)}},
+			maxSize:   50,
+			wantErr:   true,
+			requests:  1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := requests
+				if i >= len(test.responses) {
+					i = len(test.responses) - 1
+				}
+				requests++
+				if requests > 1 && r.Header.Get("If-None-Match") != `"v1"` {
+					t.Errorf("request %d has If-None-Match %q, want %q", requests, r.Header.Get("If-None-Match"), `"v1"`)
+				}
+				w.Header().Set("ETag", `"v1"`)
+				w.WriteHeader(test.responses[i].status)
+				w.Write([]byte(test.responses[i].body))
+			}))
+			defer server.Close()
+
+			loader := newTestLoader(t)
+			cfg := Config{Name: "feed", Type: TypeHTTP, URL: server.URL, MaxSize: test.maxSize, Retries: 1}
+
+			var indicators []Indicator
+			var err error
+			// Every load makes at least one request, retries make more
+			for requests < len(test.responses) {
+				indicators, err = loader.Load(cfg)
+			}
+
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if got := values(indicators); got != test.want {
+				t.Errorf("Load() = %q, want %q", got, test.want)
+			}
+			if requests != test.requests {
+				t.Errorf("made %d requests, want %d", requests, test.requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package source
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/fileManager"
+)
+
+// Parses a JSON document, reading records from the configured records path
+// and the indicator from the field path of each record
+func ParseJSON(cfg Config, r io.Reader) ([]Indicator, error) {
+	return collect(func(emit func(Indicator) error) error {
+		return parseJSON(cfg, r, unique(emit))
+	})
+}
+
+func parseJSON(cfg Config, r io.Reader, emit func(Indicator) error) error {
+	filters, err := parseExpressions(cfg.Filters)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	var document interface{}
+	err = decoder.Decode(&document)
+	if err != nil {
+		return fmt.Errorf("error decoding JSON: %w", err)
+	}
+
+	var records []interface{}
+	if cfg.Records != "" {
+		records, err = evalPath(document, cfg.Records)
+		if err != nil {
+			return err
+		}
+	} else if array, ok := document.([]interface{}); ok {
+		records = array
+	} else {
+		records = []interface{}{document}
+	}
+
+	for _, record := range records {
+		err := emitJSONRecord(cfg, filters, record, emit)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parses JSON lines, one record per line. Records are read one at a time, so
+// only the indicators are held in memory. Blank lines are skipped.
+func ParseJSONLines(cfg Config, r io.Reader) ([]Indicator, error) {
+	return collect(func(emit func(Indicator) error) error {
+		return parseJSONLines(cfg, r, unique(emit))
+	})
+}
+
+func parseJSONLines(cfg Config, r io.Reader, emit func(Indicator) error) error {
+	filters, err := parseExpressions(cfg.Filters)
+	if err != nil {
+		return err
+	}
+
+	scanner := fileManager.NewLineScanner(r, cfg.MaxLineLength)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.UseNumber()
+		var record interface{}
+		err := decoder.Decode(&record)
+		if err != nil {
+			return fmt.Errorf("error decoding JSON line %d: %w", lineNumber, err)
+		}
+
+		err = emitJSONRecord(cfg, filters, record, emit)
+		if err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return scanError(err, cfg.MaxLineLength)
+	}
+	return nil
+}
+
+// Emits the indicators of a record that passes the filters
+func emitJSONRecord(cfg Config, filters []expression, record interface{}, emit func(Indicator) error) error {
+	get := func(name string) (string, bool) {
+		path := name
+		if mapped, ok := cfg.Metadata[name]; ok {
+			path = mapped
+		}
+		values, err := evalPath(record, path)
+		if err != nil || len(values) == 0 {
+			return "", false
+		}
+		return jsonString(values), true
+	}
+
+	if !matchAll(filters, get) {
+		return nil
+	}
+
+	values := []interface{}{record}
+	if cfg.Field != "" {
+		var err error
+		values, err = evalPath(record, cfg.Field)
+		if err != nil {
+			return err
+		}
+	}
+
+	metadata := selectMetadata(cfg.Metadata, get)
+	for _, v := range flatten(values) {
+		if _, ok := v.(map[string]interface{}); ok {
+			continue
+		}
+		value := normalizeValue(jsonString([]interface{}{v}))
+		if value == "" {
+			continue
+		}
+		if err := emit(Indicator{Value: value, Metadata: metadata}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Evaluates a path like $.data[*].indicator, data[].tags[0] or ["odd key"]
+// against a decoded JSON value and returns every match
+func evalPath(v interface{}, path string) ([]interface{}, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	current := []interface{}{v}
+	for _, segment := range segments {
+		var next []interface{}
+		for _, value := range current {
+			switch {
+			case segment == "*":
+				switch t := value.(type) {
+				case []interface{}:
+					next = append(next, t...)
+				case map[string]interface{}:
+					for _, item := range t {
+						next = append(next, item)
+					}
+				}
+			case strings.HasPrefix(segment, "#"):
+				index, _ := strconv.Atoi(segment[1:])
+				if array, ok := value.([]interface{}); ok && index < len(array) {
+					next = append(next, array[index])
+				}
+			default:
+				if object, ok := value.(map[string]interface{}); ok {
+					if item, ok := object[segment]; ok {
+						next = append(next, item)
+					}
+				}
+			}
+		}
+		current = next
+	}
+	return current, nil
+}
+
+// Splits a path into keys, "*" for wildcards and "#n" for array indexes
+func parsePath(path string) ([]string, error) {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+
+	var segments []string
+	for i := 0; i < len(path); {
+		switch path[i] {
+		case '.':
+			i++
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unclosed [", path)
+			}
+			inner := strings.TrimSpace(path[i+1 : i+end])
+			switch {
+			case inner == "" || inner == "*":
+				segments = append(segments, "*")
+			case strings.HasPrefix(inner, `"`) || strings.HasPrefix(inner, "'"):
+				segments = append(segments, strings.Trim(inner, `"'`))
+			default:
+				if _, err := strconv.Atoi(inner); err != nil {
+					return nil, fmt.Errorf("invalid path %q: bad index %q", path, inner)
+				}
+				segments = append(segments, "#"+inner)
+			}
+			i += end + 1
+		default:
+			end := strings.IndexAny(path[i:], ".[")
+			if end < 0 {
+				end = len(path) - i
+			}
+			segments = append(segments, path[i:i+end])
+			i += end
+		}
+	}
+	return segments, nil
+}
+
+func flatten(values []interface{}) []interface{} {
+	var flat []interface{}
+	for _, v := range values {
+		if array, ok := v.([]interface{}); ok {
+			flat = append(flat, flatten(array)...)
+			continue
+		}
+		flat = append(flat, v)
+	}
+	return flat
+}
+
+// Formats matched values as a string, joining arrays with commas
+func jsonString(values []interface{}) string {
+	var parts []string
+	for _, v := range flatten(values) {
+		switch t := v.(type) {
+		case nil:
+		case string:
+			parts = append(parts, t)
+		case map[string]interface{}:
+			data, _ := json.Marshal(t)
+			parts = append(parts, string(data))
+		default:
+			parts = append(parts, fmt.Sprint(t))
+		}
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,74 @@
+package source
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseJSONLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		content string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "field of each record",
+			cfg:     Config{Field: "indicator"},
+			content: `{"indicator":"evil.com"}` + "\n\n" + `{"indicator":"Bad.org."}` + "\n",
+			want:    "evil.com,bad.org",
+		},
+		{
+			name:    "filters",
+			cfg:     Config{Field: "indicator", Filters: []string{"confidence >= 50"}},
+			content: `{"indicator":"evil.com","confidence":80}` + "\n" + `{"indicator":"bad.org","confidence":20}` + "\n",
+			want:    "evil.com",
+		},
+		{
+			name:    "array field",
+			cfg:     Config{Field: "hosts[*]"},
+			content: `{"hosts":["a.com","b.com","a.com"]}` + "\n",
+			want:    "a.com,b.com",
+		},
+		{
+			name:    "invalid line",
+			cfg:     Config{Field: "indicator"},
+			content: `{"indicator":"evil.com"}` + "\n" + `{"indicator":` + "\n",
+			wantErr: "error decoding JSON line 2",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			indicators, err := ParseJSONLines(test.cfg, strings.NewReader(test.content))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("ParseJSONLines() error = %v, want one containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseJSONLines() error = %v", err)
+			}
+			if got := values(indicators); got != test.want {
+				t.Errorf("ParseJSONLines() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func BenchmarkParseJSONLines(b *testing.B) {
+	line := func(dst []byte, i int) []byte {
+		dst = append(dst, `{"indicator":"host`...)
+		dst = strconv.AppendInt(dst, int64(i%benchmarkDistinct), 10)
+		return append(dst, `.example.com","confidence":80}`...)
+	}
+	input := func() io.Reader { return &generatedLines{n: benchmarkLines, line: line} }
+	cfg := Config{Field: "indicator", Filters: []string{"confidence >= 50"}}
+	benchmarkParse(b, input, func(r io.Reader) ([]Indicator, error) {
+		return ParseJSONLines(cfg, r)
+	})
+}
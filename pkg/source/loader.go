@@ -0,0 +1,125 @@
+package source
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/logging"
+)
+
+// Loader reads indicators from configured sources. DataDir holds state kept
+// between runs, such as the last good copy of remote feeds.
+type Loader struct {
+	DataDir    string
+	Logger     logging.Logger
+	HTTPClient *http.Client
+}
+
+func NewLoader(dataDir string, logger logging.Logger) *Loader {
+	return &Loader{
+		DataDir:    dataDir,
+		Logger:     logger,
+		HTTPClient: &http.Client{},
+	}
+}
+
+// Reads and parses every indicator of a source. An error means the source
+// could not be read, it is never reported as an empty source. Indicators are
+// passed on from the parser one at a time rather than collected per file or
+// archive member, so memory grows with the distinct values kept rather than
+// the size of the input. The first indicator of each value is kept.
+func (l *Loader) Load(cfg Config) ([]Indicator, error) {
+	cfg = cfg.WithDefaults()
+	return collect(func(emit func(Indicator) error) error {
+		return l.load(cfg, unique(filterIndicators(cfg, emit)))
+	})
+}
+
+func (l *Loader) load(cfg Config, emit func(Indicator) error) error {
+	// Sources that are queried rather than read as a stream of bytes
+	var query func(Config) ([]Indicator, error)
+	switch cfg.Type {
+	case TypeTAXII:
+		query = l.loadTAXII
+	case TypeMISP:
+		query = l.loadMISP
+	case TypePlugin:
+		query = l.loadPlugin
+	}
+	if query != nil {
+		indicators, err := query(cfg)
+		if err != nil {
+			return fmt.Errorf("error reading source %s: %w", cfg.Name, err)
+		}
+		return emitAll(indicators, emit)
+	}
+
+	if cfg.Type == TypeFile {
+		err := l.loadFiles(cfg, emit)
+		if err != nil {
+			return fmt.Errorf("error reading source %s: %w", cfg.Name, err)
+		}
+		return nil
+	}
+
+	r, err := l.open(cfg)
+	if err != nil {
+		return fmt.Errorf("error reading source %s: %w", cfg.Name, err)
+	}
+	defer r.Close()
+
+	err = parseStreams(cfg, cfg.Location(), r, emit)
+	if err != nil {
+		return fmt.Errorf("error parsing source %s: %w", cfg.Name, err)
+	}
+	return nil
+}
+
+func (l *Loader) open(cfg Config) (io.ReadCloser, error) {
+	switch cfg.Type {
+	case TypeHTTP:
+		return l.openHTTP(cfg)
+	default:
+		return nil, fmt.Errorf("unknown source type %q", cfg.Type)
+	}
+}
+
+// Parses a source in its configured format
+func Parse(cfg Config, r io.Reader) ([]Indicator, error) {
+	return collect(func(emit func(Indicator) error) error {
+		return parseEach(cfg, r, unique(emit))
+	})
+}
+
+// Parses a source in its configured format, calling emit for each indicator
+// as it is read. Line and record based formats are never held whole, STIX
+// bundles and MISP exports are decoded first.
+func parseEach(cfg Config, r io.Reader, emit func(Indicator) error) error {
+	switch cfg.Format {
+	case "", "text":
+		return parseText(cfg, r, emit)
+	case "stix":
+		indicators, err := ParseSTIX(r)
+		if err != nil {
+			return err
+		}
+		return emitAll(indicators, emit)
+	case "misp":
+		indicators, err := ParseMISP(cfg, r)
+		if err != nil {
+			return err
+		}
+		return emitAll(indicators, emit)
+	case "csv":
+		return parseCSV(cfg, r, emit)
+	case "json":
+		return parseJSON(cfg, r, emit)
+	case "jsonl":
+		return parseJSONLines(cfg, r, emit)
+	case "extract":
+		return parseExtract(cfg, r, emit)
+	default:
+		return fmt.Errorf("unknown source format %q", cfg.Format)
+	}
+}
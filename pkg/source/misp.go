@@ -0,0 +1,357 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const mispPageLimit = 5000
+
+// MISP attribute types synced to Umbrella
+var mispTypes = []string{"domain", "hostname", "url", "ip-dst"}
+
+type mispTag struct {
+	Name string `json:"name"`
+}
+
+type mispEvent struct {
+	UUID          string          `json:"uuid"`
+	Info          string          `json:"info"`
+	Date          string          `json:"date"`
+	ThreatLevelID string          `json:"threat_level_id"`
+	Tag           []mispTag       `json:"Tag"`
+	Attribute     []mispAttribute `json:"Attribute"`
+	Object        []struct {
+		Attribute []mispAttribute `json:"Attribute"`
+	} `json:"Object"`
+}
+
+type mispAttribute struct {
+	Type      string     `json:"type"`
+	Value     string     `json:"value"`
+	ToIDs     bool       `json:"to_ids"`
+	Timestamp string     `json:"timestamp"`
+	EventUUID string     `json:"event_uuid"`
+	Tag       []mispTag  `json:"Tag"`
+	Event     *mispEvent `json:"Event"`
+}
+
+type mispEventWrapper struct {
+	Event *mispEvent `json:"Event"`
+}
+
+// mispResponse covers the shapes of MISP JSON: a single event export, a list
+// of events, and event or attribute restSearch responses
+type mispResponse struct {
+	Event    *mispEvent      `json:"Event"`
+	Response json.RawMessage `json:"response"`
+}
+
+// Parses a MISP JSON export or restSearch response
+func ParseMISP(cfg Config, r io.Reader) ([]Indicator, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes, err := mispAttributes(content)
+	if err != nil {
+		return nil, err
+	}
+	return filterMISP(cfg, attributes, time.Now())
+}
+
+// Queries the MISP attribute restSearch endpoint page by page
+func (l *Loader) loadMISP(cfg Config) ([]Indicator, error) {
+	var attributes []mispAttribute
+
+	for page := 1; ; page++ {
+		query := map[string]interface{}{
+			"returnFormat":     "json",
+			"type":             mispTypes,
+			"includeEventTags": true,
+			"includeContext":   true,
+			"limit":            mispPageLimit,
+			"page":             page,
+		}
+		if len(cfg.Tags) > 0 {
+			query["tags"] = cfg.Tags
+		}
+		if cfg.ToIDs != nil {
+			query["to_ids"] = *cfg.ToIDs
+		}
+		if cfg.ThreatLevel > 0 {
+			levels := make([]int, 0, cfg.ThreatLevel)
+			for level := 1; level <= cfg.ThreatLevel; level++ {
+				levels = append(levels, level)
+			}
+			query["threat_level_id"] = levels
+		}
+		if cfg.Since != "" {
+			if strings.HasSuffix(cfg.Since, "d") {
+				query["last"] = cfg.Since
+			} else {
+				query["from"] = cfg.Since
+			}
+		}
+
+		content, err := l.postMISP(cfg, query)
+		if err != nil {
+			return nil, err
+		}
+
+		pageAttributes, err := mispAttributes(content)
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, pageAttributes...)
+
+		if len(pageAttributes) < mispPageLimit {
+			break
+		}
+	}
+
+	l.Logger.Debug("Received ", len(attributes), " attributes from MISP")
+	return filterMISP(cfg, attributes, time.Now())
+}
+
+func (l *Loader) postMISP(cfg Config, query map[string]interface{}) ([]byte, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	payload, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", joinURL(cfg.URL, "attributes/restSearch"), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	if cfg.APIKey != "" {
+		req.Header.Set("Authorization", cfg.APIKey)
+	}
+
+	resp, err := l.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("non-OK HTTP status: %s: %s", resp.Status, string(body))
+	}
+	return body, nil
+}
+
+// Flattens any MISP JSON shape into attributes that each carry their event
+func mispAttributes(content []byte) ([]mispAttribute, error) {
+	content = bytes.TrimSpace(content)
+
+	// A list of events
+	if bytes.HasPrefix(content, []byte("[")) {
+		var events []mispEventWrapper
+		err := json.Unmarshal(content, &events)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding MISP events: %w", err)
+		}
+		return eventAttributes(events), nil
+	}
+
+	var response mispResponse
+	err := json.Unmarshal(content, &response)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding MISP response: %w", err)
+	}
+
+	if response.Event != nil {
+		return eventAttributes([]mispEventWrapper{{Event: response.Event}}), nil
+	}
+
+	// Event restSearch responses hold a list of events
+	if bytes.HasPrefix(bytes.TrimSpace(response.Response), []byte("[")) {
+		var events []mispEventWrapper
+		err := json.Unmarshal(response.Response, &events)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding MISP events: %w", err)
+		}
+		return eventAttributes(events), nil
+	}
+
+	// Attribute restSearch responses hold a list of attributes
+	var attributes struct {
+		Attribute []mispAttribute `json:"Attribute"`
+	}
+	if len(response.Response) > 0 {
+		err = json.Unmarshal(response.Response, &attributes)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding MISP attributes: %w", err)
+		}
+	}
+	return attributes.Attribute, nil
+}
+
+func eventAttributes(events []mispEventWrapper) []mispAttribute {
+	var attributes []mispAttribute
+	for _, wrapper := range events {
+		event := wrapper.Event
+		if event == nil {
+			continue
+		}
+
+		all := event.Attribute
+		for _, object := range event.Object {
+			all = append(all, object.Attribute...)
+		}
+		for _, attribute := range all {
+			attribute.Event = event
+			attributes = append(attributes, attribute)
+		}
+	}
+	return attributes
+}
+
+// Keeps the attributes matching the configured types, tags, to_ids, threat
+// level and date filters. Attributes from restSearch may come without their
+// event, the threat level and date filters are skipped when the data is
+// missing since the query already applied them.
+func filterMISP(cfg Config, attributes []mispAttribute, now time.Time) ([]Indicator, error) {
+	since, err := parseSince(cfg.Since, now)
+	if err != nil {
+		return nil, err
+	}
+
+	wantedTypes := make(map[string]bool)
+	for _, t := range mispTypes {
+		wantedTypes[t] = true
+	}
+
+	var indicators []Indicator
+	seen := make(map[string]bool)
+	for _, attribute := range attributes {
+		if !wantedTypes[attribute.Type] || attribute.Value == "" {
+			continue
+		}
+		if cfg.ToIDs != nil && attribute.ToIDs != *cfg.ToIDs {
+			continue
+		}
+
+		event := attribute.Event
+		if event == nil {
+			event = &mispEvent{UUID: attribute.EventUUID}
+		}
+		if cfg.ThreatLevel > 0 && event.ThreatLevelID != "" {
+			level, err := strconv.Atoi(event.ThreatLevelID)
+			if err != nil || level > cfg.ThreatLevel {
+				continue
+			}
+		}
+		if !since.IsZero() {
+			date, ok := mispDate(attribute, event)
+			if ok && date.Before(since) {
+				continue
+			}
+		}
+
+		tags := mispTagNames(attribute.Tag, event.Tag)
+		if len(cfg.Tags) > 0 && !containsAny(tags, cfg.Tags) {
+			continue
+		}
+
+		value := normalizeValue(attribute.Value)
+		if seen[value] {
+			continue
+		}
+		seen[value] = true
+
+		metadata := map[string]string{}
+		if event.UUID != "" {
+			metadata["misp_event"] = event.UUID
+		}
+		if event.ThreatLevelID != "" {
+			metadata["threat_level"] = event.ThreatLevelID
+		}
+		if len(tags) > 0 {
+			metadata["labels"] = strings.Join(tags, ",")
+		}
+		indicators = append(indicators, Indicator{Value: value, Metadata: metadata})
+	}
+
+	return indicators, nil
+}
+
+// Returns when an attribute was last changed, from its own timestamp or else
+// from the date of its event
+func mispDate(attribute mispAttribute, event *mispEvent) (time.Time, bool) {
+	if seconds, err := strconv.ParseInt(attribute.Timestamp, 10, 64); err == nil {
+		return time.Unix(seconds, 0), true
+	}
+	if date, err := time.Parse("2006-01-02", event.Date); err == nil {
+		return date, true
+	}
+	return time.Time{}, false
+}
+
+// Parses a date like 2024-01-31 or a number of days like 30d into a cutoff time
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+	if strings.HasSuffix(since, "d") {
+		d, err := ParseDuration(since)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid since %q", since)
+		}
+		return now.Add(-d).Truncate(24 * time.Hour), nil
+	}
+	date, err := time.Parse("2006-01-02", since)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid since %q, expected a date like 2024-01-31 or days like 30d", since)
+	}
+	return date, nil
+}
+
+func mispTagNames(tagLists ...[]mispTag) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, tags := range tagLists {
+		for _, tag := range tags {
+			if tag.Name != "" && !seen[tag.Name] {
+				seen[tag.Name] = true
+				names = append(names, tag.Name)
+			}
+		}
+	}
+	return names
+}
+
+func containsAny(values []string, wanted []string) bool {
+	for _, v := range values {
+		for _, w := range wanted {
+			if strings.EqualFold(v, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
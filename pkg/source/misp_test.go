@@ -0,0 +1,152 @@
+package source
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMISP(t *testing.T) {
+	event := `{"uuid":"e1","date":"2026-01-10","threat_level_id":"2","Tag":[{"name":"tlp:amber"}],
+		"Attribute":[{"type":"domain","value":"Evil.com","to_ids":true},{"type":"md5","value":"abc","to_ids":true}],
+		"Object":[{"Attribute":[{"type":"url","value":"http://bad.org/x","to_ids":false}]}]}`
+	toIDs := true
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		content string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "single event export",
+			content: `{"Event":` + event + `}`,
+			want:    "evil.com,http://bad.org/x",
+		},
+		{
+			name:    "list of events",
+			content: `[{"Event":` + event + `}]`,
+			want:    "evil.com,http://bad.org/x",
+		},
+		{
+			name:    "event restSearch response",
+			content: `{"response":[{"Event":` + event + `}]}`,
+			want:    "evil.com,http://bad.org/x",
+		},
+		{
+			name:    "attribute restSearch response",
+			content: `{"response":{"Attribute":[{"type":"hostname","value":"c2.example.net"},{"type":"ip-dst","value":"10.0.0.1"}]}}`,
+			want:    "c2.example.net,10.0.0.1",
+		},
+		{
+			name:    "to_ids",
+			cfg:     Config{ToIDs: &toIDs},
+			content: `{"Event":` + event + `}`,
+			want:    "evil.com",
+		},
+		{
+			name:    "threat level above the limit",
+			cfg:     Config{ThreatLevel: 1},
+			content: `{"Event":` + event + `}`,
+			want:    "",
+		},
+		{
+			name:    "event date before since",
+			cfg:     Config{Since: "2026-02-01"},
+			content: `{"Event":` + event + `}`,
+			want:    "",
+		},
+		{
+			name:    "attribute timestamp overrides the event date",
+			cfg:     Config{Since: "2026-02-01"},
+			content: `{"Event":{"date":"2026-01-10","Attribute":[{"type":"domain","value":"new.com","timestamp":"` + unixDate("2026-02-02") + `"}]}}`,
+			want:    "new.com",
+		},
+		{
+			name:    "tags",
+			cfg:     Config{Tags: []string{"TLP:AMBER"}},
+			content: `{"response":{"Attribute":[{"type":"domain","value":"untagged.com"},{"type":"domain","value":"tagged.com","Tag":[{"name":"tlp:amber"}]}]}}`,
+			want:    "tagged.com",
+		},
+		{
+			name:    "invalid JSON",
+			content: `{"Event":`,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			indicators, err := ParseMISP(test.cfg, strings.NewReader(test.content))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseMISP() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if got := values(indicators); got != test.want {
+				t.Errorf("ParseMISP() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func unixDate(date string) string {
+	d, _ := time.Parse("2006-01-02", date)
+	return strconv.FormatInt(d.Unix(), 10)
+}
+
+func TestLoadMISP(t *testing.T) {
+	// restSearch attributes come without their event, the filters have to be
+	// applied by the query rather than dropping every attribute
+	var queries []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/attributes/restSearch" || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var query map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+			t.Errorf("invalid restSearch body: %v", err)
+		}
+		queries = append(queries, query)
+		w.Write([]byte(`{"response":{"Attribute":[
+			{"type":"domain","value":"evil.com","to_ids":true,"event_uuid":"e1"},
+			{"type":"url","value":"https://bad.org/login","to_ids":true,"event_uuid":"e2"}]}}`))
+	}))
+	defer server.Close()
+
+	cfg := Config{Name: "misp", Type: TypeMISP, URL: server.URL, APIKey: "key", ThreatLevel: 2, Since: "30d"}
+	indicators, err := newTestLoader(t).Load(cfg)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got, want := values(indicators), "evil.com,https://bad.org/login"; got != want {
+		t.Errorf("Load() = %q, want %q", got, want)
+	}
+	if got := indicators[0].Metadata["misp_event"]; got != "e1" {
+		t.Errorf("misp_event = %q, want e1", got)
+	}
+
+	if len(queries) != 1 {
+		t.Fatalf("made %d queries, want 1", len(queries))
+	}
+	if got, want := queries[0]["threat_level_id"], []interface{}{1.0, 2.0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("threat_level_id = %v, want %v", got, want)
+	}
+	if got := queries[0]["last"]; got != "30d" {
+		t.Errorf("last = %v, want 30d", got)
+	}
+
+	cfg.APIKey = "wrong"
+	if _, err := newTestLoader(t).Load(cfg); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Load() with a wrong key error = %v, want a 403", err)
+	}
+}
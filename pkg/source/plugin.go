@@ -0,0 +1,191 @@
+package source
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/fileManager"
+)
+
+const (
+	defaultPluginTimeout = 300
+	defaultPluginMaxSize = 100 * 1024 * 1024
+)
+
+// pluginLine is a line of plugin output. A line may also be a bare JSON string.
+type pluginLine struct {
+	Value    string                 `json:"value"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+// Runs a plugin executable and reads indicators from its stdout as JSON
+// lines. Stderr goes to the log. A plugin that fails, times out or writes
+// invalid output fails the source, so a broken plugin is never mistaken for
+// an empty feed.
+func (l *Loader) loadPlugin(cfg Config) ([]Indicator, error) {
+	if cfg.Command == "" {
+		return nil, errors.New("plugin source has no command")
+	}
+	for _, entry := range cfg.Env {
+		if strings.Index(entry, "=") <= 0 {
+			return nil, fmt.Errorf("invalid env entry %q of plugin %s, must be KEY=VALUE", entry, cfg.Command)
+		}
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultPluginTimeout
+	}
+	maxSize := cfg.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultPluginMaxSize
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, cfg.Command, cfg.Args...)
+	// Children of a killed plugin may hold its pipes open
+	cmd.WaitDelay = 5 * time.Second
+	cmd.Env = append(os.Environ(), cfg.Env...)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("error starting plugin %s: %w", cfg.Command, err)
+	}
+
+	var lastError string
+	stderrDone := make(chan struct{})
+	go func() {
+		defer close(stderrDone)
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			lastError = line
+			l.Logger.Info("[", cfg.Name, "] ", line)
+		}
+	}()
+
+	// Output is parsed in the background so a plugin whose children keep
+	// stdout open cannot outlast the timeout
+	type parseResult struct {
+		indicators []Indicator
+		err        error
+	}
+	done := make(chan parseResult, 1)
+	limited := &io.LimitedReader{R: stdout, N: maxSize + 1}
+	go func() {
+		indicators, err := parsePluginOutput(limited, cfg.MaxLineLength)
+		done <- parseResult{indicators, err}
+	}()
+
+	var result parseResult
+	select {
+	case result = <-done:
+		if limited.N <= 0 {
+			result.err = fmt.Errorf("plugin output exceeds the maximum size of %d bytes", maxSize)
+		}
+	case <-ctx.Done():
+	}
+	if result.err != nil {
+		cancel()
+	}
+
+	// Wait closes the pipes, so stderr is read to the end first
+	select {
+	case <-stderrDone:
+	case <-ctx.Done():
+	}
+	err = cmd.Wait()
+	<-stderrDone
+	indicators, parseErr := result.indicators, result.err
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("plugin %s timed out after %d seconds", cfg.Command, timeout)
+	}
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && lastError != "" {
+			return nil, fmt.Errorf("plugin %s exited with code %d: %s", cfg.Command, exitErr.ExitCode(), lastError)
+		}
+		return nil, fmt.Errorf("plugin %s failed: %w", cfg.Command, err)
+	}
+
+	l.Logger.Debug("Plugin ", cfg.Command, " wrote ", len(indicators), " indicators")
+	return indicators, nil
+}
+
+// Parses JSON lines like {"value": "evil.com", "metadata": {"confidence": 80}}
+// or "evil.com". Blank lines are skipped.
+func parsePluginOutput(r io.Reader, maxLineLength int) ([]Indicator, error) {
+	var indicators []Indicator
+	seen := make(map[string]bool)
+
+	scanner := fileManager.NewLineScanner(r, maxLineLength)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var parsed pluginLine
+		if strings.HasPrefix(line, `"`) {
+			err := json.Unmarshal([]byte(line), &parsed.Value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid plugin output on line %d: %w", lineNumber, err)
+			}
+		} else {
+			decoder := json.NewDecoder(strings.NewReader(line))
+			decoder.UseNumber()
+			err := decoder.Decode(&parsed)
+			if err != nil {
+				return nil, fmt.Errorf("invalid plugin output on line %d: %w", lineNumber, err)
+			}
+		}
+
+		value := normalizeValue(parsed.Value)
+		if value == "" {
+			return nil, fmt.Errorf("invalid plugin output on line %d: missing value", lineNumber)
+		}
+		if seen[value] {
+			continue
+		}
+		seen[value] = true
+
+		var metadata map[string]string
+		if len(parsed.Metadata) > 0 {
+			metadata = make(map[string]string, len(parsed.Metadata))
+			for k, v := range parsed.Metadata {
+				metadata[k] = jsonString([]interface{}{v})
+			}
+		}
+		indicators = append(indicators, Indicator{Value: value, Metadata: metadata})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading plugin output: %w", scanError(err, maxLineLength))
+	}
+	return indicators, nil
+}
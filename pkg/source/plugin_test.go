@@ -0,0 +1,100 @@
+package source
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Writes a shell script plugin and returns its path
+func pluginScript(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "plugin.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPlugin(t *testing.T) {
+	if _, err := os.Stat("/bin/sh"); err != nil {
+		t.Skip("plugin tests run shell scripts")
+	}
+
+	tests := []struct {
+		name    string
+		script  string
+		cfg     Config
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "JSON lines and strings",
+			script: `echo '{"value":"Evil.com","metadata":{"confidence":80}}'; echo; echo '"bad.org"'; echo '"evil.com"'` + "\n",
+			want:   "evil.com,bad.org",
+		},
+		{
+			name:   "arguments and environment",
+			script: `echo "\"$1.$API_Token\""` + "\n",
+			cfg:    Config{Args: []string{"host"}, Env: []string{"API_Token=example.com"}},
+			want:   "host.example.com",
+		},
+		{
+			name:    "invalid env entry",
+			script:  "true\n",
+			cfg:     Config{Env: []string{"=value"}},
+			wantErr: `invalid env entry "=value"`,
+		},
+		{
+			name:    "non-zero exit",
+			script:  "echo '\"evil.com\"'; echo 'feed unavailable' >&2; exit 3\n",
+			wantErr: "exited with code 3: feed unavailable",
+		},
+		{
+			name:    "timeout",
+			script:  "exec sleep 30\n",
+			cfg:     Config{Timeout: 1},
+			wantErr: "timed out after 1 seconds",
+		},
+		{
+			name:    "invalid JSON",
+			script:  `echo '"evil.com"'; echo '{"value":'` + "\n",
+			wantErr: "invalid plugin output on line 2",
+		},
+		{
+			name:    "missing value",
+			script:  `echo '{"metadata":{}}'` + "\n",
+			wantErr: "invalid plugin output on line 1: missing value",
+		},
+		{
+			name:    "output over the maximum size",
+			script:  `echo '"evil.com"'; echo '"bad.org"'` + "\n",
+			cfg:     Config{MaxSize: 12},
+			wantErr: "exceeds the maximum size of 12 bytes",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := test.cfg
+			cfg.Name = "plugin"
+			cfg.Type = TypePlugin
+			cfg.Command = pluginScript(t, test.script)
+
+			indicators, err := newTestLoader(t).Load(cfg)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("Load() error = %v, want one containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if got := values(indicators); got != test.want {
+				t.Errorf("Load() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package source
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/fileManager"
+)
+
+// Collects the indicators a parse function emits
+func collect(parse func(emit func(Indicator) error) error) ([]Indicator, error) {
+	var indicators []Indicator
+	err := parse(func(indicator Indicator) error {
+		indicators = append(indicators, indicator)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return indicators, nil
+}
+
+// Wraps emit so it is only called with the first indicator of each value.
+// The values seen are the part of reading a source that stays in memory, so
+// it grows with the distinct indicators rather than the size of the source.
+func unique(emit func(Indicator) error) func(Indicator) error {
+	seen := make(map[string]bool)
+	return func(indicator Indicator) error {
+		if seen[indicator.Value] {
+			return nil
+		}
+		seen[indicator.Value] = true
+		return emit(indicator)
+	}
+}
+
+func emitAll(indicators []Indicator, emit func(Indicator) error) error {
+	for _, indicator := range indicators {
+		if err := emit(indicator); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Explains a line over the configured maximum length
+func scanError(err error, maxLineLength int) error {
+	if !errors.Is(err, bufio.ErrTooLong) {
+		return err
+	}
+	if maxLineLength <= 0 {
+		maxLineLength = fileManager.DefaultMaxLineLength
+	}
+	return fmt.Errorf("line longer than %d bytes, raise maxlinelength to read it: %w", maxLineLength, err)
+}
@@ -0,0 +1,87 @@
+package source
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Umbrella limits the length of destination comments
+const maxCommentLength = 255
+
+// Indicator is a single destination read from a source along with any
+// metadata the source carried for it
+type Indicator struct {
+	Value    string
+	Metadata map[string]string
+}
+
+// Returns the metadata formatted as a destination comment
+func (i Indicator) Comment() string {
+	if len(i.Metadata) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(i.Metadata))
+	for k := range i.Metadata {
+		if k != "comment" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	if comment, ok := i.Metadata["comment"]; ok {
+		parts = append(parts, comment)
+	}
+	for _, k := range keys {
+		parts = append(parts, k+"="+i.Metadata[k])
+	}
+
+	// Truncated on a rune boundary so the API never gets invalid UTF-8
+	comment := strings.ToValidUTF8(strings.Join(parts, " "), "")
+	if len(comment) > maxCommentLength {
+		cut := maxCommentLength
+		for cut > 0 && !utf8.RuneStart(comment[cut]) {
+			cut--
+		}
+		comment = comment[:cut]
+	}
+	return comment
+}
+
+// Returns the expiry date from the "expires" metadata, a date like
+// 2026-12-01 or an RFC 3339 time
+func (i Indicator) Expires() (time.Time, bool) {
+	expires, ok := i.Metadata["expires"]
+	if !ok {
+		return time.Time{}, false
+	}
+	if t, err := time.Parse("2006-01-02", expires); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.RFC3339, expires); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// Returns the values of the indicators
+func Values(indicators []Indicator) []string {
+	values := make([]string, len(indicators))
+	for i, indicator := range indicators {
+		values[i] = indicator.Value
+	}
+	return values
+}
+
+// Lowercases domains and strips trailing dots. URLs are left as they are
+// since paths are case sensitive.
+func normalizeValue(value string) string {
+	value = strings.TrimSpace(value)
+	if strings.Contains(value, "/") {
+		return value
+	}
+	return strings.TrimSuffix(strings.ToLower(value), ".")
+}
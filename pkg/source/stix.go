@@ -0,0 +1,128 @@
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	stixComparisonPattern = regexp.MustCompile(`(domain-name|url|ipv4-addr):value\s*=\s*'((?:[^'\\]|\\.)*)'`)
+	stixComplexPattern    = regexp.MustCompile(`(?i)\b(AND|FOLLOWEDBY|REPEATS|WITHIN|START|STOP|NOT|MATCHES|LIKE|ISSUBSET|ISSUPERSET)\b|!=|<|>`)
+)
+
+type stixBundle struct {
+	Type    string            `json:"type"`
+	Objects []json.RawMessage `json:"objects"`
+}
+
+type stixIndicator struct {
+	Type        string   `json:"type"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Pattern     string   `json:"pattern"`
+	PatternType string   `json:"pattern_type"`
+	ValidFrom   string   `json:"valid_from"`
+	ValidUntil  string   `json:"valid_until"`
+	Revoked     bool     `json:"revoked"`
+	Confidence  *int     `json:"confidence"`
+	Labels      []string `json:"labels"`
+}
+
+// Parses the indicator objects of a STIX 2.1 bundle. Only simple patterns
+// comparing domain-name, url or ipv4-addr values, optionally joined by OR,
+// are supported. Revoked indicators and indicators outside their validity
+// window are skipped.
+func ParseSTIX(r io.Reader) ([]Indicator, error) {
+	var bundle stixBundle
+	err := json.NewDecoder(r).Decode(&bundle)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding STIX bundle: %w", err)
+	}
+	if bundle.Type != "bundle" {
+		return nil, fmt.Errorf("expected a STIX bundle, got %q", bundle.Type)
+	}
+
+	return parseSTIXObjects(bundle.Objects, time.Now())
+}
+
+// Returns the indicators of the active indicator objects
+func parseSTIXObjects(objects []json.RawMessage, now time.Time) ([]Indicator, error) {
+	var indicators []Indicator
+	seen := make(map[string]bool)
+
+	for _, object := range objects {
+		var si stixIndicator
+		err := json.Unmarshal(object, &si)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding STIX object: %w", err)
+		}
+		if si.Type != "indicator" || (si.PatternType != "" && si.PatternType != "stix") {
+			continue
+		}
+		if !si.isActive(now) {
+			continue
+		}
+
+		metadata := si.metadata()
+		for _, value := range stixPatternValues(si.Pattern) {
+			if seen[value] {
+				continue
+			}
+			seen[value] = true
+			indicators = append(indicators, Indicator{Value: value, Metadata: metadata})
+		}
+	}
+
+	return indicators, nil
+}
+
+// Returns the values compared in a simple pattern, or nothing for patterns
+// whose meaning depends on more than a single observable value
+func stixPatternValues(pattern string) []string {
+	withoutStrings := stixComparisonPattern.ReplaceAllString(pattern, "")
+	if stixComplexPattern.MatchString(withoutStrings) {
+		return nil
+	}
+
+	var values []string
+	for _, match := range stixComparisonPattern.FindAllStringSubmatch(pattern, -1) {
+		value := strings.NewReplacer(`\'`, `'`, `\\`, `\`).Replace(match[2])
+		values = append(values, normalizeValue(value))
+	}
+	return values
+}
+
+func (si stixIndicator) isActive(now time.Time) bool {
+	if si.Revoked {
+		return false
+	}
+	if validFrom, err := time.Parse(time.RFC3339, si.ValidFrom); err == nil && now.Before(validFrom) {
+		return false
+	}
+	if validUntil, err := time.Parse(time.RFC3339, si.ValidUntil); err == nil && !now.Before(validUntil) {
+		return false
+	}
+	return true
+}
+
+func (si stixIndicator) metadata() map[string]string {
+	metadata := make(map[string]string)
+	if si.Name != "" {
+		metadata["comment"] = si.Name
+	}
+	if si.Confidence != nil {
+		metadata["confidence"] = strconv.Itoa(*si.Confidence)
+	}
+	if len(si.Labels) > 0 {
+		metadata["labels"] = strings.Join(si.Labels, ",")
+	}
+	if validUntil, err := time.Parse(time.RFC3339, si.ValidUntil); err == nil {
+		metadata["expires"] = validUntil.UTC().Format("2006-01-02")
+	}
+	return metadata
+}
@@ -0,0 +1,330 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/fileManager"
+)
+
+const (
+	taxiiMediaType  = "application/taxii+json;version=2.1"
+	taxiiPageLimit  = 1000
+	taxiiMaxPages   = 10000
+	taxiiDateHeader = "X-TAXII-Date-Added-Last"
+)
+
+type taxiiDiscovery struct {
+	Default  string   `json:"default"`
+	APIRoots []string `json:"api_roots"`
+}
+
+type taxiiCollection struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	CanRead bool   `json:"can_read"`
+}
+
+type taxiiCollections struct {
+	Collections []taxiiCollection `json:"collections"`
+}
+
+type taxiiEnvelope struct {
+	More    bool              `json:"more"`
+	Next    string            `json:"next"`
+	Objects []json.RawMessage `json:"objects"`
+}
+
+// taxiiObject is the last version seen of a STIX indicator
+type taxiiObject struct {
+	Values     []string          `json:"values"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	ValidFrom  string            `json:"validFrom,omitempty"`
+	ValidUntil string            `json:"validUntil,omitempty"`
+}
+
+// taxiiState is kept per collection. Each poll only fetches objects added
+// after LastAdded, so the indicators seen so far are accumulated here.
+type taxiiState struct {
+	Collection string                 `json:"collection"`
+	LastAdded  string                 `json:"lastAdded,omitempty"`
+	PolledAt   time.Time              `json:"polledAt"`
+	Objects    map[string]taxiiObject `json:"objects"`
+}
+
+// Polls a TAXII 2.1 collection for objects added since the last poll and
+// returns every indicator still active. Revoked or expired indicators are
+// retracted. If polling fails, the indicators from the last poll are used.
+func (l *Loader) loadTAXII(cfg Config) ([]Indicator, error) {
+	statePath := l.taxiiStatePath(cfg)
+	state := taxiiState{Objects: make(map[string]taxiiObject)}
+	hasState, err := fileManager.IsExists(statePath)
+	if err != nil {
+		return nil, err
+	}
+	if hasState {
+		content, err := fileManager.ReadFile(statePath)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(content, &state)
+		if err != nil {
+			return nil, fmt.Errorf("error reading TAXII state %s: %w", statePath, err)
+		}
+		if state.Objects == nil {
+			state.Objects = make(map[string]taxiiObject)
+		}
+	}
+
+	err = l.pollTAXII(cfg, &state)
+	if err != nil {
+		if !hasState {
+			return nil, err
+		}
+		l.Logger.Warn("Error polling ", cfg.Name, ": ", err)
+		l.Logger.Warn("Using indicators from last poll at ", state.PolledAt.Format(time.RFC3339))
+		return state.indicators(time.Now()), nil
+	}
+
+	content, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	err = fileManager.WriteFileAtomic(statePath, content, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return state.indicators(time.Now()), nil
+}
+
+func (l *Loader) pollTAXII(cfg Config, state *taxiiState) error {
+	apiRoot := cfg.APIRoot
+	if apiRoot == "" {
+		var discovery taxiiDiscovery
+		err := l.getTAXII(cfg, cfg.URL, nil, &discovery, nil)
+		if err != nil {
+			return fmt.Errorf("error reading TAXII discovery: %w", err)
+		}
+		apiRoot = discovery.Default
+		if apiRoot == "" && len(discovery.APIRoots) > 0 {
+			apiRoot = discovery.APIRoots[0]
+		}
+		if apiRoot == "" {
+			return fmt.Errorf("TAXII server %s has no API roots", cfg.URL)
+		}
+	}
+	apiRoot = resolveURL(cfg.URL, apiRoot)
+
+	var collections taxiiCollections
+	err := l.getTAXII(cfg, joinURL(apiRoot, "collections/"), nil, &collections, nil)
+	if err != nil {
+		return fmt.Errorf("error reading TAXII collections: %w", err)
+	}
+
+	var collection *taxiiCollection
+	for i, c := range collections.Collections {
+		if c.ID == cfg.Collection || strings.EqualFold(c.Title, cfg.Collection) {
+			collection = &collections.Collections[i]
+			break
+		}
+	}
+	if collection == nil {
+		return fmt.Errorf("TAXII collection %q not found", cfg.Collection)
+	}
+	if !collection.CanRead {
+		return fmt.Errorf("TAXII collection %q is not readable", cfg.Collection)
+	}
+	if state.Collection != collection.ID {
+		state.Collection = collection.ID
+		state.LastAdded = ""
+		state.Objects = make(map[string]taxiiObject)
+	}
+
+	objectsURL := joinURL(apiRoot, "collections/"+collection.ID+"/objects/")
+	lastAdded := state.LastAdded
+	next := ""
+	received := 0
+
+	for page := 0; page < taxiiMaxPages; page++ {
+		params := url.Values{}
+		params.Set("limit", fmt.Sprint(taxiiPageLimit))
+		params.Set("match[type]", "indicator")
+		if state.LastAdded != "" {
+			params.Set("added_after", state.LastAdded)
+		}
+		if next != "" {
+			params.Set("next", next)
+		}
+
+		var envelope taxiiEnvelope
+		var header http.Header
+		err := l.getTAXII(cfg, objectsURL, params, &envelope, &header)
+		if err != nil {
+			return fmt.Errorf("error reading TAXII objects: %w", err)
+		}
+
+		for _, object := range envelope.Objects {
+			err := state.apply(object)
+			if err != nil {
+				return err
+			}
+		}
+		received += len(envelope.Objects)
+
+		if added := header.Get(taxiiDateHeader); added != "" {
+			lastAdded = added
+		}
+
+		if !envelope.More || len(envelope.Objects) == 0 {
+			break
+		}
+		if envelope.Next != "" {
+			next = envelope.Next
+		} else if lastAdded != state.LastAdded {
+			// Servers without next paging continue from the last added date
+			state.LastAdded = lastAdded
+		} else {
+			break
+		}
+	}
+
+	l.Logger.Info("Received ", received, " new objects from TAXII collection ", collection.Title)
+	state.LastAdded = lastAdded
+	state.PolledAt = time.Now().UTC()
+	return nil
+}
+
+// Records the latest version of an indicator, retracting it when revoked
+func (s *taxiiState) apply(object json.RawMessage) error {
+	var si stixIndicator
+	err := json.Unmarshal(object, &si)
+	if err != nil {
+		return fmt.Errorf("error decoding STIX object: %w", err)
+	}
+	if si.Type != "indicator" || (si.PatternType != "" && si.PatternType != "stix") {
+		return nil
+	}
+
+	if si.Revoked {
+		delete(s.Objects, si.ID)
+		return nil
+	}
+
+	values := stixPatternValues(si.Pattern)
+	if len(values) == 0 {
+		delete(s.Objects, si.ID)
+		return nil
+	}
+	s.Objects[si.ID] = taxiiObject{Values: values, Metadata: si.metadata(), ValidFrom: si.ValidFrom, ValidUntil: si.ValidUntil}
+	return nil
+}
+
+// Returns the indicators of every object that is valid at now. Objects that
+// are not valid yet stay in the state until they are.
+func (s *taxiiState) indicators(now time.Time) []Indicator {
+	ids := make([]string, 0, len(s.Objects))
+	for id := range s.Objects {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var indicators []Indicator
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		object := s.Objects[id]
+		if validFrom, err := time.Parse(time.RFC3339, object.ValidFrom); err == nil && now.Before(validFrom) {
+			continue
+		}
+		if validUntil, err := time.Parse(time.RFC3339, object.ValidUntil); err == nil && !now.Before(validUntil) {
+			continue
+		}
+		for _, value := range object.Values {
+			if !seen[value] {
+				seen[value] = true
+				indicators = append(indicators, Indicator{Value: value, Metadata: object.Metadata})
+			}
+		}
+	}
+	return indicators
+}
+
+func (l *Loader) getTAXII(cfg Config, target string, params url.Values, v interface{}, header *http.Header) error {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return err
+	}
+	if params != nil {
+		req.URL.RawQuery = params.Encode()
+	}
+	req.Header.Set("Accept", taxiiMediaType)
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	if cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	} else if cfg.Username != "" {
+		req.SetBasicAuth(cfg.Username, cfg.Password)
+	}
+
+	resp, err := l.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("non-OK HTTP status: %s: %s", resp.Status, string(body))
+	}
+	if header != nil {
+		*header = resp.Header
+	}
+
+	if len(body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, v)
+}
+
+func (l *Loader) taxiiStatePath(cfg Config) string {
+	sum := sha256.Sum256([]byte(cfg.URL + "|" + cfg.APIRoot + "|" + cfg.Collection))
+	return filepath.Join(l.DataDir, "taxii-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// Resolves an API root, which discovery may return as a relative path
+func resolveURL(base string, ref string) string {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return baseURL.ResolveReference(refURL).String()
+}
+
+func joinURL(base string, path string) string {
+	return strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(path, "/")
+}
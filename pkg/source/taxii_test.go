@@ -0,0 +1,256 @@
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// taxiiObjectAt is a STIX object as a stand-in TAXII server stores it, with
+// the date it was added to the collection
+type taxiiObjectAt struct {
+	added  string
+	object map[string]interface{}
+}
+
+// taxiiServer is a stand-in TAXII 2.1 server with one API root and a
+// collection that pages its objects two at a time
+type taxiiServer struct {
+	t         *testing.T
+	readable  bool
+	objects   []taxiiObjectAt
+	down      bool
+	afterSeen []string
+}
+
+func (s *taxiiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if r.Header.Get("Accept") != taxiiMediaType {
+		s.t.Errorf("request for %s has Accept %q", r.URL.Path, r.Header.Get("Accept"))
+	}
+	if r.Header.Get("Authorization") != "Bearer secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", taxiiMediaType)
+	switch r.URL.Path {
+	case "/taxii2/":
+		json.NewEncoder(w).Encode(map[string]interface{}{"api_roots": []string{"/api1/"}})
+	case "/api1/collections/":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"collections": []map[string]interface{}{
+				{"id": "91a7b528-80eb-42ed-a74d-c6fbd5a26116", "title": "Indicators", "can_read": s.readable},
+			},
+		})
+	case "/api1/collections/91a7b528-80eb-42ed-a74d-c6fbd5a26116/objects/":
+		s.serveObjects(w, r)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *taxiiServer) serveObjects(w http.ResponseWriter, r *http.Request) {
+	after := r.URL.Query().Get("added_after")
+	s.afterSeen = append(s.afterSeen, after)
+
+	var matching []taxiiObjectAt
+	for _, o := range s.objects {
+		if after == "" || o.added > after {
+			matching = append(matching, o)
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool { return matching[i].added < matching[j].added })
+
+	start, _ := strconv.Atoi(r.URL.Query().Get("next"))
+	end := start + 2
+	if end > len(matching) {
+		end = len(matching)
+	}
+
+	envelope := map[string]interface{}{"more": end < len(matching)}
+	if end < len(matching) {
+		envelope["next"] = strconv.Itoa(end)
+	}
+	var objects []map[string]interface{}
+	for _, o := range matching[start:end] {
+		objects = append(objects, o.object)
+	}
+	envelope["objects"] = objects
+	if len(objects) > 0 {
+		w.Header().Set(taxiiDateHeader, matching[end-1].added)
+	}
+	json.NewEncoder(w).Encode(envelope)
+}
+
+func stixDomainIndicator(id string, domain string, extra map[string]interface{}) map[string]interface{} {
+	object := map[string]interface{}{
+		"type":         "indicator",
+		"id":           "indicator--" + id,
+		"pattern":      fmt.Sprintf("[domain-name:value = '%s']", domain),
+		"pattern_type": "stix",
+	}
+	for k, v := range extra {
+		object[k] = v
+	}
+	return object
+}
+
+func TestLoadTAXII(t *testing.T) {
+	future := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+	past := time.Now().Add(-24 * time.Hour).UTC().Format(time.RFC3339)
+
+	server := &taxiiServer{
+		t:        t,
+		readable: true,
+		objects: []taxiiObjectAt{
+			{"2026-01-01T00:00:01Z", stixDomainIndicator("1", "one.example.com", nil)},
+			{"2026-01-01T00:00:02Z", stixDomainIndicator("2", "two.example.com", map[string]interface{}{"valid_until": future})},
+			{"2026-01-01T00:00:03Z", stixDomainIndicator("3", "expired.example.com", map[string]interface{}{"valid_until": past})},
+			{"2026-01-01T00:00:04Z", map[string]interface{}{"type": "malware", "id": "malware--4"}},
+			{"2026-01-01T00:00:05Z", stixDomainIndicator("5", "five.example.com", map[string]interface{}{"valid_from": past})},
+			{"2026-01-01T00:00:00Z", stixDomainIndicator("7", "later.example.com", map[string]interface{}{"valid_from": future})},
+		},
+	}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	loader := newTestLoader(t)
+	cfg := Config{Name: "taxii", Type: TypeTAXII, URL: httpServer.URL + "/taxii2/", Collection: "indicators", Token: "secret"}
+
+	// First poll pages through the whole collection
+	indicators, err := loader.Load(cfg)
+	if err != nil {
+		t.Fatalf("first Load() error = %v", err)
+	}
+	if got, want := values(indicators), "one.example.com,two.example.com,five.example.com"; got != want {
+		t.Errorf("first Load() = %q, want %q", got, want)
+	}
+
+	// The next poll only asks for objects added since, applying revocations
+	server.afterSeen = nil
+	server.objects = append(server.objects,
+		taxiiObjectAt{"2026-01-02T00:00:00Z", stixDomainIndicator("1", "one.example.com", map[string]interface{}{"revoked": true})},
+		taxiiObjectAt{"2026-01-02T00:00:01Z", stixDomainIndicator("6", "six.example.com", nil)},
+	)
+	indicators, err = loader.Load(cfg)
+	if err != nil {
+		t.Fatalf("second Load() error = %v", err)
+	}
+	if got, want := values(indicators), "two.example.com,five.example.com,six.example.com"; got != want {
+		t.Errorf("second Load() = %q, want %q", got, want)
+	}
+	if len(server.afterSeen) == 0 || server.afterSeen[0] != "2026-01-01T00:00:05Z" {
+		t.Errorf("second poll added_after = %v, want 2026-01-01T00:00:05Z", server.afterSeen)
+	}
+
+	// A failed poll falls back to the indicators of the last poll
+	server.down = true
+	indicators, err = loader.Load(cfg)
+	if err != nil {
+		t.Fatalf("Load() with the server down error = %v", err)
+	}
+	if got, want := values(indicators), "two.example.com,five.example.com,six.example.com"; got != want {
+		t.Errorf("Load() with the server down = %q, want %q", got, want)
+	}
+}
+
+func TestTAXIIStateIndicators(t *testing.T) {
+	state := taxiiState{Objects: make(map[string]taxiiObject)}
+	for _, object := range []map[string]interface{}{
+		stixDomainIndicator("1", "always.example.com", nil),
+		stixDomainIndicator("2", "window.example.com", map[string]interface{}{"valid_from": "2026-03-01T00:00:00Z", "valid_until": "2026-04-01T00:00:00Z"}),
+		stixDomainIndicator("3", "from.example.com", map[string]interface{}{"valid_from": "2026-03-15T00:00:00Z"}),
+	} {
+		content, err := json.Marshal(object)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := state.apply(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		now  string
+		want string
+	}{
+		{now: "2026-02-01T00:00:00Z", want: "always.example.com"},
+		{now: "2026-03-01T00:00:00Z", want: "always.example.com,window.example.com"},
+		{now: "2026-03-20T00:00:00Z", want: "always.example.com,window.example.com,from.example.com"},
+		{now: "2026-04-01T00:00:00Z", want: "always.example.com,from.example.com"},
+	}
+	for _, test := range tests {
+		now, _ := time.Parse(time.RFC3339, test.now)
+		if got := values(state.indicators(now)); got != test.want {
+			t.Errorf("indicators(%s) = %q, want %q", test.now, got, test.want)
+		}
+	}
+}
+
+func TestLoadTAXIIErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      func(url string) Config
+		readable bool
+		wantErr  string
+	}{
+		{
+			name:     "unknown collection",
+			cfg:      func(url string) Config { return Config{URL: url + "/taxii2/", Collection: "other", Token: "secret"} },
+			readable: true,
+			wantErr:  "not found",
+		},
+		{
+			name: "unreadable collection",
+			cfg: func(url string) Config {
+				return Config{URL: url + "/taxii2/", Collection: "Indicators", Token: "secret"}
+			},
+			readable: false,
+			wantErr:  "not readable",
+		},
+		{
+			name:     "unauthorized",
+			cfg:      func(url string) Config { return Config{URL: url + "/taxii2/", Collection: "Indicators"} },
+			readable: true,
+			wantErr:  "401",
+		},
+		{
+			name: "explicit API root skips discovery",
+			cfg: func(url string) Config {
+				return Config{URL: url + "/missing/", APIRoot: url + "/api1/", Collection: "Indicators", Token: "secret"}
+			},
+			readable: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(&taxiiServer{t: t, readable: test.readable})
+			defer server.Close()
+
+			cfg := test.cfg(server.URL)
+			cfg.Name = "taxii"
+			cfg.Type = TypeTAXII
+			_, err := newTestLoader(t).Load(cfg)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Load() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("Load() error = %v, want one containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package source
+
+import (
+	"io"
+	"strings"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/fileManager"
+)
+
+// Parses a blocklist with one destination per line. Blank lines and lines
+// starting with # are skipped. Anything after a # preceded by whitespace is an
+// inline comment, where key=value pairs become metadata and the remaining
+// text becomes the "comment" metadata:
+//
+//	evil.com  # ticket=INC123 expires=2026-12-01 confidence=80
+func ParseText(cfg Config, r io.Reader) ([]Indicator, error) {
+	return collect(func(emit func(Indicator) error) error {
+		return parseText(cfg, r, unique(emit))
+	})
+}
+
+func parseText(cfg Config, r io.Reader, emit func(Indicator) error) error {
+	scanner := fileManager.NewLineScanner(r, cfg.MaxLineLength)
+	for scanner.Scan() {
+		if indicator, ok := parseLine(scanner.Text()); ok {
+			if err := emit(indicator); err != nil {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return scanError(err, cfg.MaxLineLength)
+	}
+	return nil
+}
+
+func parseLine(line string) (Indicator, bool) {
+	line = strings.TrimSpace(strings.TrimPrefix(line, "\ufeff"))
+	if line == "" || strings.HasPrefix(line, "#") {
+		return Indicator{}, false
+	}
+
+	value, comment := line, ""
+	for i := 1; i < len(line); i++ {
+		if line[i] == '#' && (line[i-1] == ' ' || line[i-1] == '\t') {
+			value, comment = line[:i], line[i+1:]
+			break
+		}
+	}
+
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return Indicator{}, false
+	}
+
+	return Indicator{
+		Value:    normalizeValue(fields[0]),
+		Metadata: parseMetadata(comment),
+	}, true
+}
+
+// Splits a comment into key=value metadata and free text
+func parseMetadata(comment string) map[string]string {
+	fields := strings.Fields(comment)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	metadata := make(map[string]string)
+	var text []string
+	for _, field := range fields {
+		if k, v, ok := strings.Cut(field, "="); ok && k != "" && v != "" {
+			metadata[strings.ToLower(k)] = v
+			continue
+		}
+		text = append(text, field)
+	}
+	if len(text) > 0 {
+		metadata["comment"] = strings.Join(text, " ")
+	}
+
+	return metadata
+}
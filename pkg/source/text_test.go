@@ -0,0 +1,147 @@
+package source
+
+import (
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseText(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		want     string
+		metadata map[string]string
+	}{
+		{name: "one per line", content: "evil.com\nbad.org\n", want: "evil.com,bad.org"},
+		{name: "comments and blank lines", content: "# header\n\n  evil.com  \n#bad.org\n", want: "evil.com"},
+		{name: "normalized and deduplicated", content: "Evil.COM.\nevil.com\nhttp://evil.com/Path\n", want: "evil.com,http://evil.com/Path"},
+		{name: "byte order mark", content: "\ufeffevil.com\n", want: "evil.com"},
+		{
+			name:     "inline metadata",
+			content:  "evil.com  # phishing kit ticket=INC123 Confidence=80\nevil.com # duplicate\n",
+			want:     "evil.com",
+			metadata: map[string]string{"comment": "phishing kit", "ticket": "INC123", "confidence": "80"},
+		},
+		// Only a hash after whitespace starts a comment. The fragment is
+		// dropped later, when sync normalizes destinations.
+		{name: "hash inside a URL", content: "http://evil.com/#frag\n", want: "http://evil.com/#frag"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			indicators, err := ParseText(Config{}, strings.NewReader(test.content))
+			if err != nil {
+				t.Fatalf("ParseText() error = %v", err)
+			}
+			if got := values(indicators); got != test.want {
+				t.Errorf("ParseText() = %q, want %q", got, test.want)
+			}
+			if test.metadata != nil && !equalMetadata(indicators[0].Metadata, test.metadata) {
+				t.Errorf("metadata = %v, want %v", indicators[0].Metadata, test.metadata)
+			}
+		})
+	}
+}
+
+func TestParseTextLineLength(t *testing.T) {
+	_, err := ParseText(Config{MaxLineLength: 16}, strings.NewReader("evil.com\n"+strings.Repeat("a", 32)+".com\n"))
+	if err == nil || !strings.Contains(err.Error(), "raise maxlinelength") {
+		t.Errorf("ParseText() error = %v, want one about maxlinelength", err)
+	}
+}
+
+func equalMetadata(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Lines of the generated benchmark inputs, half of them repeating an
+// earlier value
+const (
+	benchmarkLines    = 2000000
+	benchmarkDistinct = benchmarkLines / 2
+)
+
+// generatedLines is an input of n lines written by line as it is read, so a
+// benchmark measures the parser rather than the input held in memory
+type generatedLines struct {
+	n, i    int
+	line    func(dst []byte, i int) []byte
+	buf     []byte
+	pending []byte
+}
+
+func (g *generatedLines) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(g.pending) == 0 {
+			if g.i == g.n {
+				break
+			}
+			g.buf = append(g.line(g.buf[:0], g.i), '\n')
+			g.pending = g.buf
+			g.i++
+		}
+		copied := copy(p[n:], g.pending)
+		g.pending = g.pending[copied:]
+		n += copied
+	}
+	if n == 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+func domainLine(dst []byte, i int) []byte {
+	dst = append(dst, "host"...)
+	dst = strconv.AppendInt(dst, int64(i%benchmarkDistinct), 10)
+	return append(dst, ".example.com"...)
+}
+
+// Runs parse over a generated input, reporting the heap its result still
+// holds per input line next to the allocations
+func benchmarkParse(b *testing.B, input func() io.Reader, parse func(r io.Reader) ([]Indicator, error)) {
+	b.ReportAllocs()
+	var retained int64
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		r := input()
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		b.StartTimer()
+
+		indicators, err := parse(r)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.StopTimer()
+		if len(indicators) != benchmarkDistinct {
+			b.Fatalf("parsed %d indicators, want %d", len(indicators), benchmarkDistinct)
+		}
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		retained = int64(after.HeapAlloc) - int64(before.HeapAlloc)
+		runtime.KeepAlive(indicators)
+		b.StartTimer()
+	}
+	b.ReportMetric(float64(retained)/benchmarkLines, "retained-B/line")
+}
+
+func BenchmarkParseText(b *testing.B) {
+	input := func() io.Reader { return &generatedLines{n: benchmarkLines, line: domainLine} }
+	benchmarkParse(b, input, func(r io.Reader) ([]Indicator, error) {
+		return ParseText(Config{}, r)
+	})
+}
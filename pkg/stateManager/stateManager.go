@@ -0,0 +1,276 @@
+package stateManager
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/fileManager"
+	"github.com/thegrumpyape/umbrellasync/pkg/rules"
+)
+
+// Record is what is known locally about an indicator asserted by a source
+type Record struct {
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+type SourceState struct {
+	Indicators map[string]Record `json:"indicators"`
+}
+
+// TargetRecord is an indicator of a target list and the sources asserting it
+type TargetRecord struct {
+	Sources   []string  `json:"sources"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+type TargetState struct {
+	Indicators map[string]TargetRecord `json:"indicators"`
+}
+
+type State struct {
+	Sources map[string]*SourceState  `json:"sources"`
+	Targets map[string]*TargetState  `json:"targets,omitempty"`
+	Traces  map[string][]rules.Trace `json:"traces,omitempty"`
+}
+
+// StateManager keeps per source indicator state between runs in a data file.
+// Changes are made to the state read at the start of the run and written once
+// by Flush, which takes a lock file and re-reads the data file first so
+// concurrent runs do not overwrite each other.
+type StateManager struct {
+	path    string
+	mu      sync.Mutex
+	state   *State
+	pending []func(state *State)
+}
+
+func New(path string) *StateManager {
+	return &StateManager{path: path}
+}
+
+// Records that a source currently asserts values. Values seen for the first
+// time get a first-seen date and values no longer asserted are forgotten.
+// Returns the record of every value. Nothing is written unless persist is set.
+func (sm *StateManager) Observe(source string, values []string, now time.Time, persist bool) (map[string]Record, error) {
+	var records map[string]Record
+
+	observe := func(state *State) {
+		previous := state.source(source).Indicators
+		current := make(map[string]Record, len(values))
+		for _, value := range values {
+			record, ok := previous[value]
+			if !ok {
+				record = Record{FirstSeen: now}
+			}
+			record.LastSeen = now
+			current[value] = record
+		}
+		state.Sources[source] = &SourceState{Indicators: current}
+		records = current
+	}
+
+	err := sm.change(persist, observe)
+	return records, err
+}
+
+// Records the sources asserting each indicator of a target list. Values no
+// source asserts any more are forgotten. Nothing is written unless persist is set.
+func (sm *StateManager) ObserveTarget(target string, provenance map[string][]string, now time.Time, persist bool) error {
+	return sm.change(persist, func(state *State) {
+		previous := state.target(target).Indicators
+		current := make(map[string]TargetRecord, len(provenance))
+		for value, sources := range provenance {
+			record, ok := previous[value]
+			if !ok {
+				record = TargetRecord{FirstSeen: now}
+			}
+			record.Sources = sources
+			record.LastSeen = now
+			current[value] = record
+		}
+		state.Targets[target] = &TargetState{Indicators: current}
+	})
+}
+
+// Most rule traces kept per source, so a rule rewriting every entry of a
+// large feed does not copy the feed into the state
+const maxTraces = 10000
+
+// Replaces the rule trace of a source with the one from the latest run.
+// Drops are kept before rewrites when there are more than maxTraces.
+func (sm *StateManager) RecordTraces(source string, traces []rules.Trace, persist bool) error {
+	traces = capTraces(traces, maxTraces)
+	return sm.change(persist, func(state *State) {
+		if len(traces) == 0 {
+			delete(state.Traces, source)
+			return
+		}
+		state.Traces[source] = traces
+	})
+}
+
+// Returns at most max traces, the drops first and then the first rewrites,
+// in their original order
+func capTraces(traces []rules.Trace, max int) []rules.Trace {
+	if len(traces) <= max {
+		return traces
+	}
+
+	drops := 0
+	for _, trace := range traces {
+		if trace.Dropped() {
+			drops++
+		}
+	}
+	rewrites := max - drops
+	if rewrites < 0 {
+		drops, rewrites = max, 0
+	}
+
+	capped := make([]rules.Trace, 0, max)
+	for _, trace := range traces {
+		if trace.Dropped() && drops > 0 {
+			capped = append(capped, trace)
+			drops--
+		} else if !trace.Dropped() && rewrites > 0 {
+			capped = append(capped, trace)
+			rewrites--
+		}
+	}
+	return capped
+}
+
+// Applies a change to the state of this run, queueing it for Flush when
+// persist is set. The data file is only read by the first change.
+func (sm *StateManager) change(persist bool, change func(state *State)) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.state == nil {
+		state, err := sm.read()
+		if err != nil {
+			return err
+		}
+		sm.state = state
+	}
+
+	change(sm.state)
+	if persist {
+		sm.pending = append(sm.pending, change)
+	}
+	return nil
+}
+
+// Writes the queued changes in one update of the data file. They are applied
+// again to the data file as it is now, keeping what other runs wrote since.
+func (sm *StateManager) Flush() error {
+	sm.mu.Lock()
+	pending := sm.pending
+	sm.pending = nil
+	sm.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+	return sm.Update(func(state *State) error {
+		for _, change := range pending {
+			change(state)
+		}
+		return nil
+	})
+}
+
+// Reads the state without locking
+func (sm *StateManager) Load() (*State, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.read()
+}
+
+// Applies a change to the state under the lock file
+func (sm *StateManager) Update(change func(state *State) error) error {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	unlock, err := fileManager.LockFile(sm.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	state, err := sm.read()
+	if err != nil {
+		return err
+	}
+
+	err = change(state)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return fileManager.WriteFileAtomic(sm.path, content, 0644)
+}
+
+func (sm *StateManager) read() (*State, error) {
+	state := &State{
+		Sources: make(map[string]*SourceState),
+		Targets: make(map[string]*TargetState),
+		Traces:  make(map[string][]rules.Trace),
+	}
+
+	exists, err := fileManager.IsExists(sm.path)
+	if err != nil || !exists {
+		return state, err
+	}
+
+	content, err := fileManager.ReadFile(sm.path)
+	if err != nil {
+		return nil, err
+	}
+	if len(content) == 0 {
+		return state, nil
+	}
+
+	err = json.Unmarshal(content, state)
+	if err != nil {
+		return nil, fmt.Errorf("error reading state %s: %w", sm.path, err)
+	}
+	if state.Sources == nil {
+		state.Sources = make(map[string]*SourceState)
+	}
+	if state.Targets == nil {
+		state.Targets = make(map[string]*TargetState)
+	}
+	if state.Traces == nil {
+		state.Traces = make(map[string][]rules.Trace)
+	}
+	return state, nil
+}
+
+// Returns the state of a source, creating it if needed
+func (s *State) source(name string) *SourceState {
+	ss, ok := s.Sources[name]
+	if !ok || ss.Indicators == nil {
+		ss = &SourceState{Indicators: make(map[string]Record)}
+		s.Sources[name] = ss
+	}
+	return ss
+}
+
+// Returns the state of a target, creating it if needed
+func (s *State) target(name string) *TargetState {
+	ts, ok := s.Targets[name]
+	if !ok || ts.Indicators == nil {
+		ts = &TargetState{Indicators: make(map[string]TargetRecord)}
+		s.Targets[name] = ts
+	}
+	return ts
+}
@@ -0,0 +1,115 @@
+package stateManager
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/rules"
+)
+
+func TestCapTraces(t *testing.T) {
+	drop := func(value string) rules.Trace { return rules.Trace{Rule: "r", Original: value} }
+	rewrite := func(value string) rules.Trace { return rules.Trace{Rule: "r", Original: value, Result: "x"} }
+
+	tests := []struct {
+		name   string
+		traces []rules.Trace
+		max    int
+		want   []rules.Trace
+	}{
+		{
+			name:   "under the cap",
+			traces: []rules.Trace{rewrite("a"), drop("b")},
+			max:    2,
+			want:   []rules.Trace{rewrite("a"), drop("b")},
+		},
+		{
+			name:   "drops are kept before rewrites in order",
+			traces: []rules.Trace{rewrite("a"), rewrite("b"), drop("c"), rewrite("d"), drop("e")},
+			max:    3,
+			want:   []rules.Trace{rewrite("a"), drop("c"), drop("e")},
+		},
+		{
+			name:   "more drops than the cap",
+			traces: []rules.Trace{rewrite("a"), drop("b"), drop("c"), drop("d")},
+			max:    2,
+			want:   []rules.Trace{drop("b"), drop("c")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := capTraces(test.traces, test.max); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("capTraces() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestObserveFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	first := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	second := first.Add(24 * time.Hour)
+
+	run := New(path)
+	if _, err := run.Observe("a", []string{"evil.com", "bad.org"}, first, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run.Observe("b", []string{"evil.com"}, first, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("state written before Flush, Stat() error = %v", err)
+	}
+
+	// Another run flushing in between keeps its source
+	other := New(path)
+	if _, err := other.Observe("c", []string{"other.net"}, first, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := run.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := New(path).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for source, count := range map[string]int{"a": 2, "b": 1, "c": 1} {
+		if got := len(state.Sources[source].Indicators); got != count {
+			t.Errorf("source %s has %d indicators, want %d", source, got, count)
+		}
+	}
+
+	// The next run keeps first-seen dates and forgets what is no longer asserted
+	next := New(path)
+	records, err := next.Observe("a", []string{"evil.com", "new.com"}, second, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Record{
+		"evil.com": {FirstSeen: first, LastSeen: second},
+		"new.com":  {FirstSeen: second, LastSeen: second},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("Observe() = %v, want %v", records, want)
+	}
+
+	// Changes without persist are never written
+	if err := next.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	state, err = New(path).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := state.Sources["a"].Indicators["bad.org"]; !ok {
+		t.Errorf("a dry run changed the state of source a to %v", state.Sources["a"].Indicators)
+	}
+}
@@ -14,6 +14,18 @@ import (
 	"golang.org/x/oauth2/clientcredentials"
 )
 
+// UmbrellaHTTPError is returned by Request when Umbrella answers with a
+// non-OK HTTP status. The raw body is kept so callers can inspect rejections.
+type UmbrellaHTTPError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+func (e *UmbrellaHTTPError) Error() string {
+	return fmt.Sprintf("non-OK HTTP status: %s: %s", e.Status, string(e.Body))
+}
+
 type UmbrellaClient struct {
 	client   *http.Client
 	hostname string
@@ -104,7 +116,7 @@ func (u *UmbrellaClient) Request(method string, url string, headers map[string]s
 
 	// Checks if HTTP Error occurred
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
-		return UmbrellaResponse{}, fmt.Errorf("non-OK HTTP status: %s: %s", resp.Status, string(body))
+		return UmbrellaResponse{}, &UmbrellaHTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: body}
 	}
 
 	// Unmarshals json response into UmbrellaResponse model
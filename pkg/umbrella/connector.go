@@ -5,24 +5,25 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
-	"net/url"
-	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/thegrumpyape/umbrellasync/pkg/configurationManager"
+	"github.com/thegrumpyape/umbrellasync/pkg/ignoreManager"
 	"github.com/thegrumpyape/umbrellasync/pkg/logging"
 )
 
 type UmbrellaConnector struct {
 	configurationManager configurationManager.ConfigurationManager
+	ignoreManager        *ignoreManager.IgnoreManager
 	log                  logging.Logger
 	client               *UmbrellaClient
 }
 
-func New(client *UmbrellaClient, configurationManager configurationManager.ConfigurationManager, logger logging.Logger) (*UmbrellaConnector, error) {
+func New(client *UmbrellaClient, configurationManager configurationManager.ConfigurationManager, ignoreManager *ignoreManager.IgnoreManager, logger logging.Logger) (*UmbrellaConnector, error) {
 	return &UmbrellaConnector{
 		configurationManager: configurationManager,
+		ignoreManager:        ignoreManager,
 		log:                  logger,
 		client:               client,
 	}, nil
@@ -87,11 +88,12 @@ func (u *UmbrellaConnector) GetDestinationList(id int) (DestinationList, error)
 }
 
 // Creates a new destination list
-func (u *UmbrellaConnector) CreateDestinationList(access string, isGlobal bool, name string) (DestinationList, error) {
+func (u *UmbrellaConnector) CreateDestinationList(access string, isGlobal bool, name string, bundleTypeId int) (DestinationList, error) {
 	payload := map[string]interface{}{
-		"access":   access,
-		"isGlobal": isGlobal,
-		"name":     name,
+		"access":       access,
+		"isGlobal":     isGlobal,
+		"name":         name,
+		"bundleTypeId": bundleTypeId,
 	}
 
 	jsonData, err := json.Marshal(payload)
@@ -202,66 +204,116 @@ func (u *UmbrellaConnector) GetDestinations(id int, limit int) ([]Destination, e
 	return allDestinations, nil
 }
 
-// Add destinations to a destination list
-func (u *UmbrellaConnector) AddDestinations(destinationList DestinationList, destinationsToAdd []string, chunkSize int) (DestinationList, error) {
-	destinationsToAdd, err := u.ValidateDestinationValues(destinationsToAdd)
+// Add destinations to a destination list, returning the destinations
+// Umbrella accepted. Invalid, ignored and rejected destinations are left out.
+func (u *UmbrellaConnector) AddDestinations(destinationList DestinationList, destinationsToAdd []NewDestination, chunkSize int) (DestinationList, []string, error) {
+	destinationsToAdd, err := u.ValidateDestinations(destinationsToAdd)
 	if err != nil {
-		return DestinationList{}, err
+		return DestinationList{}, nil, err
 	}
 
 	u.log.Info("Adding ", len(destinationsToAdd), " destinations")
 
+	var accepted []string
 	for i := 0; i < len(destinationsToAdd); i += chunkSize {
 		end := i + chunkSize
 		if end > len(destinationsToAdd) {
 			end = len(destinationsToAdd)
 		}
 
-		var addPayload []NewDestination
-		for _, destination := range destinationsToAdd[i:end] {
-			addPayload = append(addPayload, NewDestination{Destination: destination})
+		u.log.Debug("Adding destinations ", i, "-", end)
+		chunkAccepted, err := u.submitDestinations(&destinationList, destinationsToAdd[i:end], 0)
+		for _, destination := range chunkAccepted {
+			accepted = append(accepted, destination.Destination)
 		}
-
-		endpoint := fmt.Sprintf("/destinationlists/%d/destinations", destinationList.ID)
-		jsonData, err := json.Marshal(addPayload)
 		if err != nil {
-			return destinationList, err
+			return destinationList, accepted, fmt.Errorf("error adding destinations %d-%d: %w", i, end, err)
 		}
+	}
 
-		headers := map[string]string{
-			"Content-Type": "application/json",
-		}
+	return destinationList, accepted, nil
+}
 
-		u.log.Debug("Adding destinations ", i, "-", i+chunkSize)
-		res, err := u.client.Post("policies", endpoint, headers, nil, bytes.NewBuffer(jsonData))
-		if err != nil {
-			u.log.Warn("Error adding destinations ", i, "-", i+chunkSize)
-			var umbrellaError UmbrellaResponseError
-			err = unmarshalUmbrellaResponse(res, &umbrellaError)
-			if err != nil {
-				u.log.Error(err)
-				continue
-			}
-			umbrellaMessage := fmt.Sprint(umbrellaError.Message)
-			if strings.Contains(umbrellaMessage, "high_volume_list_domain") {
-				highVolumeDomain := strings.Split(umbrellaMessage, "\\/")[2]
-				u.log.Warn("Umbrella rejected ", highVolumeDomain, " as a high volume domain")
-				u.log.Warn("Adding ", highVolumeDomain, " to ignore list")
-				u.configurationManager.Append("highvolumedomains", highVolumeDomain)
-			} else {
-				u.log.Error(umbrellaMessage)
-			}
+// Deepest a rejected chunk is bisected, enough to isolate one entry of a 500 entry chunk
+const maxBisectDepth = 10
+
+// Posts a chunk of destinations. When Umbrella rejects the chunk, the offending
+// entries are dropped and the rest is resubmitted. If a high volume rejection
+// does not name the offending entries, the chunk is bisected until they are
+// isolated. Any other rejection, such as a malformed request or a full list,
+// is returned as an error.
+func (u *UmbrellaConnector) submitDestinations(destinationList *DestinationList, chunk []NewDestination, depth int) ([]NewDestination, error) {
+	if len(chunk) == 0 {
+		return nil, nil
+	}
+
+	res, err := u.postDestinations(destinationList.ID, chunk)
+	if err == nil {
+		return chunk, unmarshalUmbrellaResponse(res, destinationList)
+	}
+
+	rejection, ok := parseRejection(err)
+	if !ok {
+		return nil, err
+	}
+
+	offending := rejection.Offending(chunk)
+	if len(offending) > 0 {
+		u.rejectDestinations(offending, rejection)
+		return u.submitDestinations(destinationList, without(chunk, offending), depth)
+	}
+
+	if !rejection.HighVolume {
+		return nil, err
+	}
+	if len(chunk) == 1 {
+		u.rejectDestinations(chunk, rejection)
+		return nil, nil
+	}
+	if depth >= maxBisectDepth {
+		return nil, fmt.Errorf("could not isolate the rejected destinations after %d bisections: %w", depth, err)
+	}
+
+	u.log.Debug("Bisecting rejected chunk of ", len(chunk), " destinations")
+	mid := len(chunk) / 2
+	accepted, err := u.submitDestinations(destinationList, chunk[:mid], depth+1)
+	if err != nil {
+		return accepted, err
+	}
+	secondAccepted, err := u.submitDestinations(destinationList, chunk[mid:], depth+1)
+	return append(accepted, secondAccepted...), err
+}
+
+func (u *UmbrellaConnector) postDestinations(id int, destinations []NewDestination) (UmbrellaResponse, error) {
+	jsonData, err := json.Marshal(destinations)
+	if err != nil {
+		return UmbrellaResponse{}, err
+	}
+
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	endpoint := fmt.Sprintf("/destinationlists/%d/destinations", id)
+	return u.client.Post("policies", endpoint, headers, nil, bytes.NewBuffer(jsonData))
+}
+
+// Logs rejected destinations and records high volume domains so they are ignored on later runs
+func (u *UmbrellaConnector) rejectDestinations(destinations []NewDestination, rejection Rejection) {
+	for _, destination := range destinations {
+		if !rejection.HighVolume {
+			u.log.Warn("Umbrella rejected ", destination.Destination, ": ", rejection.Message)
 			continue
 		}
 
-		err = unmarshalUmbrellaResponse(res, &destinationList)
+		highVolumeDomain := DestinationHost(destination.Destination)
+		u.log.Warn("Umbrella rejected ", highVolumeDomain, " as a high volume domain")
+		u.log.Warn("Adding ", highVolumeDomain, " to ignore list")
+		err := u.ignoreManager.Add(highVolumeDomain, ignoreManager.HighVolume, rejection.Message)
 		if err != nil {
 			u.log.Error(err)
-			continue
 		}
 	}
-
-	return destinationList, nil
 }
 
 // Removes destinations from a destination list
@@ -329,69 +381,51 @@ func mapDestinationIDs(destinations []Destination) map[string]int {
 	return destinationMap
 }
 
-func (u *UmbrellaConnector) ValidateDestinationValues(destinations []string) ([]string, error) {
-	var validURLs []string
-	var highVolumeDomains []interface{}
+// Validates destinations, keeping the comment of each valid destination
+func (u *UmbrellaConnector) ValidateDestinations(destinations []NewDestination) ([]NewDestination, error) {
+	var validDestinations []NewDestination
 	var ignoreCount int
 
-	hvd := u.configurationManager.Get("highvolumedomains")
-	if hvd != nil {
-		var ok bool
-		highVolumeDomains, ok = hvd.([]interface{})
-		if !ok {
-			return nil, fmt.Errorf("invalid type for highvolumedomains")
-		}
-	}
-
-	isHighVolumeDomain := func(host string, domains []interface{}) bool {
-		for _, domain := range domains {
-			pattern := `(^|\.)` + regexp.QuoteMeta(domain.(string)) + `$`
-			matched, err := regexp.MatchString(pattern, host)
-			if err != nil {
-				return false
-			}
-			if matched {
-				return true
-			}
-		}
-		return false
-	}
-
 	u.log.Debug("Validating domains")
 
 	for _, d := range destinations {
-		dUrl, err := url.Parse(d)
+		valid, ok, err := u.validateDestination(d.Destination)
 		if err != nil {
-			ignoreCount++
-			u.log.Debug("Ignoring ", d)
-			continue
-		}
-
-		host, _, err := net.SplitHostPort(dUrl.Host)
-		if err != nil {
-			host = dUrl.Host
+			return nil, err
 		}
-		if net.ParseIP(host) != nil {
+		if !ok {
 			ignoreCount++
-			u.log.Debug("Ignoring ", d)
+			u.log.Debug("Ignoring ", d.Destination)
 			continue
 		}
+		validDestinations = append(validDestinations, NewDestination{Destination: valid, Comment: d.Comment})
+	}
 
-		if isHighVolumeDomain(host, highVolumeDomains) {
-			ignoreCount++
-			u.log.Debug("Ignoring ", d)
-			continue
-		}
+	u.log.Info("Ignoring ", ignoreCount, " destinations")
+	return validDestinations, nil
+}
 
-		url := dUrl.Scheme + "://" + host + dUrl.Path
-		if dUrl.RawQuery != "" {
-			url = url + "?" + dUrl.RawQuery
-		}
-		validURLs = append(validURLs, url)
+// Returns the destination as it should be sent to Umbrella, or false if it
+// is not a valid destination or is ignored
+func (u *UmbrellaConnector) validateDestination(d string) (string, bool, error) {
+	normalized, ok := NormalizeDestination(d)
+	if !ok {
+		return "", false, nil
+	}
+
+	host := DestinationHost(normalized)
+	if net.ParseIP(strings.Trim(host, "[]")) != nil {
+		return "", false, nil
 	}
 
-	u.log.Info("Ignoring ", ignoreCount, " URLs")
-	return validURLs, nil
+	ignored, err := u.ignoreManager.Matches(host)
+	if err != nil {
+		return "", false, err
+	}
+	if ignored {
+		return "", false, nil
+	}
+	return normalized, true, nil
 }
 
 func CreateJSONPayload(data interface{}) (*bytes.Buffer, error) {
@@ -403,6 +437,22 @@ func CreateJSONPayload(data interface{}) (*bytes.Buffer, error) {
 	return bytes.NewBuffer(jsonData), nil
 }
 
+// Returns the destinations of s that are not in remove
+func without(s []NewDestination, remove []NewDestination) []NewDestination {
+	removeMap := make(map[string]bool)
+	for _, r := range remove {
+		removeMap[r.Destination] = true
+	}
+
+	var kept []NewDestination
+	for _, v := range s {
+		if !removeMap[v.Destination] {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
 func RemoveAtIndex(s []string, index int) []string {
 	return append(s[:index], s[index+1:]...)
 }
@@ -0,0 +1,62 @@
+package umbrella
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Returns true if the destination is a URL rather than a bare domain or IP.
+// DNS policy lists can only hold domains, URLs need a web policy list.
+func IsURL(destination string) bool {
+	return strings.Contains(destination, "://") || strings.Contains(destination, "/")
+}
+
+// Returns the lowercased host of a destination, which may be a bare domain or a URL
+func DestinationHost(destination string) string {
+	if strings.Contains(destination, "://") {
+		if u, err := url.Parse(destination); err == nil {
+			return strings.ToLower(u.Hostname())
+		}
+	}
+	host := strings.SplitN(destination, "/", 2)[0]
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
+
+// Returns a destination as the connector sends it to Umbrella, or false if it
+// has no host. Domains are lowercased without a trailing dot. URLs keep their
+// scheme, path and query but lose the port and fragment, which destination
+// lists do not hold. Planning on these values keeps them equal to what a list
+// holds after the sync.
+func NormalizeDestination(destination string) (string, bool) {
+	destination = strings.TrimSpace(destination)
+	raw := destination
+	if !strings.Contains(destination, "://") {
+		raw = "http://" + destination
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return "", false
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	// Bare domains are kept as domains
+	if !IsURL(destination) {
+		return host, true
+	}
+
+	normalized := host + u.Path
+	if strings.Contains(destination, "://") {
+		normalized = u.Scheme + "://" + normalized
+	}
+	if u.RawQuery != "" {
+		normalized = normalized + "?" + u.RawQuery
+	}
+	return normalized, true
+}
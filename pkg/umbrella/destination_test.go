@@ -0,0 +1,62 @@
+package umbrella
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeDestination(t *testing.T) {
+	tests := []struct {
+		destination string
+		want        string
+		ok          bool
+	}{
+		{destination: "Evil.COM.", want: "evil.com", ok: true},
+		{destination: " evil.com ", want: "evil.com", ok: true},
+		{destination: "http://evil.com/#frag", want: "http://evil.com/", ok: true},
+		{destination: "http://evil.com:8080/x", want: "http://evil.com/x", ok: true},
+		{destination: "https://Evil.com/Path?a=1#top", want: "https://evil.com/Path?a=1", ok: true},
+		{destination: "evil.com/path", want: "evil.com/path", ok: true},
+		{destination: "evil.com:8080/path", want: "evil.com/path", ok: true},
+		{destination: "http://[2001:db8::1]:80/x", want: "http://[2001:db8::1]/x", ok: true},
+		{destination: "http:///path", ok: false},
+		{destination: "", ok: false},
+		{destination: "http://evil.com/%zz", ok: false},
+	}
+
+	for _, test := range tests {
+		got, ok := NormalizeDestination(test.destination)
+		if got != test.want || ok != test.ok {
+			t.Errorf("NormalizeDestination(%q) = %q, %v, want %q, %v", test.destination, got, ok, test.want, test.ok)
+		}
+		if !ok {
+			continue
+		}
+		// A list read back after the sync holds the normalized value, which must not change again
+		if again, _ := NormalizeDestination(got); again != got {
+			t.Errorf("NormalizeDestination(%q) = %q, want it unchanged", got, again)
+		}
+	}
+}
+
+func TestValidateDestinations(t *testing.T) {
+	connector, _ := newTestConnector(t, nil)
+	if err := connector.ignoreManager.Add("ignored.com", "ignored", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	valid, err := connector.ValidateDestinations([]NewDestination{
+		{Destination: "http://Evil.com:8080/x#frag", Comment: "kept"},
+		{Destination: "www.ignored.com"},
+		{Destination: "http://192.0.2.1/x"},
+		{Destination: "[2001:db8::1]"},
+		{Destination: "evil.org."},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []NewDestination{{Destination: "http://evil.com/x", Comment: "kept"}, {Destination: "evil.org"}}
+	if !reflect.DeepEqual(valid, want) {
+		t.Errorf("ValidateDestinations() = %v, want %v", valid, want)
+	}
+}
@@ -4,6 +4,14 @@ import (
 	"encoding/json"
 )
 
+// Destination list bundle types. DNS lists are used by DNS policies and can
+// only hold domains and IPs, web lists are used by web (SWG) policies and
+// can also hold URLs.
+const (
+	BundleTypeDNS = 1
+	BundleTypeWeb = 2
+)
+
 type Status struct {
 	Code int    `json:"code"`
 	Text string `json:"text"`
@@ -25,6 +33,7 @@ type Destination struct {
 
 type NewDestination struct {
 	Destination string `json:"destination"`
+	Comment     string `json:"comment,omitempty"`
 }
 
 type DestinationListMeta struct {
@@ -50,6 +59,14 @@ type DestinationList struct {
 	Meta                 DestinationListMeta `json:"meta"`
 }
 
+// Lists created before bundle types existed report no bundle type and are DNS lists
+func (dl DestinationList) BundleType() int {
+	if dl.BundleTypeId == 0 {
+		return BundleTypeDNS
+	}
+	return dl.BundleTypeId
+}
+
 type UmbrellaResponse struct {
 	Status Status           `json:"status"`
 	Meta   Meta             `json:"meta"`
@@ -0,0 +1,107 @@
+package umbrella
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const highVolumeRejection = "high_volume_list_domain"
+
+var (
+	rejectedURLPattern    = regexp.MustCompile(`(?i)[a-z][a-z0-9+.-]*://[^\s"'<>\]\[,]+`)
+	rejectedDomainPattern = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}\b`)
+)
+
+// Rejection describes a request that Umbrella refused because of the
+// destinations it contained rather than because of auth or availability.
+type Rejection struct {
+	StatusCode int
+	Message    string
+	HighVolume bool
+	Hosts      []string
+}
+
+// parseRejection extracts a Rejection from an error returned by the client.
+// The second return value is false when the error is not a destination rejection.
+func parseRejection(err error) (Rejection, bool) {
+	var httpErr *UmbrellaHTTPError
+	if !errors.As(err, &httpErr) {
+		return Rejection{}, false
+	}
+	if httpErr.StatusCode != http.StatusBadRequest && httpErr.StatusCode != http.StatusUnprocessableEntity {
+		return Rejection{}, false
+	}
+
+	message := string(httpErr.Body)
+	var umbrellaError UmbrellaResponseError
+	if json.Unmarshal(httpErr.Body, &umbrellaError) == nil && umbrellaError.Message != nil {
+		message = flattenMessage(umbrellaError.Message)
+	}
+	message = strings.ReplaceAll(message, `\/`, "/")
+
+	return Rejection{
+		StatusCode: httpErr.StatusCode,
+		Message:    message,
+		HighVolume: strings.Contains(message, highVolumeRejection),
+		Hosts:      extractHosts(message),
+	}, true
+}
+
+// Returns the destinations of chunk whose host is named in the rejection
+func (r Rejection) Offending(chunk []NewDestination) []NewDestination {
+	if len(r.Hosts) == 0 {
+		return nil
+	}
+
+	named := make(map[string]bool)
+	for _, host := range r.Hosts {
+		named[host] = true
+	}
+
+	var offending []NewDestination
+	for _, destination := range chunk {
+		if named[DestinationHost(destination.Destination)] {
+			offending = append(offending, destination)
+		}
+	}
+	return offending
+}
+
+func flattenMessage(message interface{}) string {
+	if s, ok := message.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Sprint(message)
+	}
+	return string(data)
+}
+
+func extractHosts(message string) []string {
+	seen := make(map[string]bool)
+	var hosts []string
+	add := func(host string) {
+		host = strings.ToLower(strings.TrimSuffix(host, "."))
+		if host != "" && !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+
+	for _, match := range rejectedURLPattern.FindAllString(message, -1) {
+		if u, err := url.Parse(match); err == nil {
+			add(u.Hostname())
+		}
+	}
+	for _, match := range rejectedDomainPattern.FindAllString(message, -1) {
+		add(match)
+	}
+
+	return hosts
+}
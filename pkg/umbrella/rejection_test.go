@@ -0,0 +1,245 @@
+package umbrella
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/ignoreManager"
+)
+
+func TestParseRejection(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		ok         bool
+		highVolume bool
+		hosts      []string
+	}{
+		{
+			name: "not an HTTP error",
+			err:  errors.New("connection reset"),
+		},
+		{
+			name: "unauthorized",
+			err:  &UmbrellaHTTPError{StatusCode: http.StatusUnauthorized, Body: []byte(`{"message":"unauthorized"}`)},
+		},
+		{
+			name:       "high volume domain in JSON message",
+			err:        &UmbrellaHTTPError{StatusCode: http.StatusBadRequest, Body: []byte(`{"message":"high_volume_list_domain: google.com"}`)},
+			ok:         true,
+			highVolume: true,
+			hosts:      []string{"google.com"},
+		},
+		{
+			name:       "escaped URL in structured message",
+			err:        &UmbrellaHTTPError{StatusCode: http.StatusUnprocessableEntity, Body: []byte(`{"message":{"destination":"https:\/\/Evil.example.com\/path"}}`)},
+			ok:         true,
+			highVolume: false,
+			hosts:      []string{"evil.example.com"},
+		},
+		{
+			name:  "rejection without hosts",
+			err:   &UmbrellaHTTPError{StatusCode: http.StatusBadRequest, Body: []byte(`{"message":"Invalid request"}`)},
+			ok:    true,
+			hosts: nil,
+		},
+		{
+			name:  "plain text body",
+			err:   &UmbrellaHTTPError{StatusCode: http.StatusBadRequest, Body: []byte(`bad destination bad.example.org.`)},
+			ok:    true,
+			hosts: []string{"bad.example.org"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rejection, ok := parseRejection(test.err)
+			if ok != test.ok {
+				t.Fatalf("parseRejection() ok = %v, want %v", ok, test.ok)
+			}
+			if !ok {
+				return
+			}
+			if rejection.HighVolume != test.highVolume {
+				t.Errorf("HighVolume = %v, want %v", rejection.HighVolume, test.highVolume)
+			}
+			if !reflect.DeepEqual(rejection.Hosts, test.hosts) {
+				t.Errorf("Hosts = %v, want %v", rejection.Hosts, test.hosts)
+			}
+		})
+	}
+}
+
+func TestRejectionOffending(t *testing.T) {
+	chunk := []NewDestination{
+		{Destination: "google.com"},
+		{Destination: "https://Google.com/search"},
+		{Destination: "evil.com"},
+		{Destination: "mail.google.com"},
+	}
+
+	tests := []struct {
+		name  string
+		hosts []string
+		want  []string
+	}{
+		{name: "no hosts", hosts: nil, want: nil},
+		{name: "domain and URL with the same host", hosts: []string{"google.com"}, want: []string{"google.com", "https://Google.com/search"}},
+		{name: "host not in chunk", hosts: []string{"other.com"}, want: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, d := range (Rejection{Hosts: test.hosts}).Offending(chunk) {
+				got = append(got, d.Destination)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Offending() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+type testLogger struct{}
+
+func (testLogger) Debug(args ...interface{}) {}
+func (testLogger) Info(args ...interface{})  {}
+func (testLogger) Warn(args ...interface{})  {}
+func (testLogger) Error(args ...interface{}) {}
+
+// Starts a stand-in for the destinations endpoint that answers each posted
+// chunk with reject, counting the requests
+func newTestConnector(t *testing.T, reject func(destinations []NewDestination) (int, string)) (*UmbrellaConnector, *int) {
+	requests := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var destinations []NewDestination
+		if err := json.NewDecoder(r.Body).Decode(&destinations); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		status, message := reject(destinations)
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]string{"message": message})
+			return
+		}
+		w.Write([]byte(`{"status":{"code":200},"data":{"id":1,"name":"test"}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client := &UmbrellaClient{
+		client:   server.Client(),
+		hostname: strings.TrimPrefix(server.URL, "https://"),
+		version:  "v2",
+		log:      testLogger{},
+	}
+	connector := &UmbrellaConnector{
+		ignoreManager: ignoreManager.New(filepath.Join(t.TempDir(), "ignore.json")),
+		log:           testLogger{},
+		client:        client,
+	}
+	return connector, &requests
+}
+
+func TestSubmitDestinations(t *testing.T) {
+	chunk := make([]NewDestination, 64)
+	for i := range chunk {
+		chunk[i] = NewDestination{Destination: "host" + string(rune('a'+i%26)) + strings.Repeat("x", i/26) + ".example.com"}
+	}
+
+	t.Run("high volume without host is bisected", func(t *testing.T) {
+		bad := chunk[17].Destination
+		connector, requests := newTestConnector(t, func(destinations []NewDestination) (int, string) {
+			for _, d := range destinations {
+				if d.Destination == bad {
+					return http.StatusBadRequest, "high_volume_list_domain"
+				}
+			}
+			return http.StatusOK, ""
+		})
+
+		list := &DestinationList{ID: 1}
+		accepted, err := connector.submitDestinations(list, chunk, 0)
+		if err != nil {
+			t.Fatalf("submitDestinations() error = %v", err)
+		}
+		if len(accepted) != len(chunk)-1 {
+			t.Errorf("accepted %d destinations, want %d", len(accepted), len(chunk)-1)
+		}
+		for _, d := range accepted {
+			if d.Destination == bad {
+				t.Errorf("rejected %s reported as accepted", bad)
+			}
+		}
+		if *requests > 2*7+1 {
+			t.Errorf("made %d requests to isolate one entry", *requests)
+		}
+		ignored, err := connector.ignoreManager.Matches(bad)
+		if err != nil || !ignored {
+			t.Errorf("%s not added to the ignore list", bad)
+		}
+	})
+
+	t.Run("named host is dropped without bisecting", func(t *testing.T) {
+		bad := chunk[3].Destination
+		connector, requests := newTestConnector(t, func(destinations []NewDestination) (int, string) {
+			for _, d := range destinations {
+				if d.Destination == bad {
+					return http.StatusBadRequest, "high_volume_list_domain: " + bad
+				}
+			}
+			return http.StatusOK, ""
+		})
+
+		accepted, err := connector.submitDestinations(&DestinationList{ID: 1}, chunk, 0)
+		if err != nil {
+			t.Fatalf("submitDestinations() error = %v", err)
+		}
+		if len(accepted) != len(chunk)-1 {
+			t.Errorf("accepted %d destinations, want %d", len(accepted), len(chunk)-1)
+		}
+		if *requests != 2 {
+			t.Errorf("made %d requests, want 2", *requests)
+		}
+	})
+
+	t.Run("other rejections are returned", func(t *testing.T) {
+		connector, requests := newTestConnector(t, func(destinations []NewDestination) (int, string) {
+			return http.StatusBadRequest, "destination list is full"
+		})
+
+		_, err := connector.submitDestinations(&DestinationList{ID: 1}, chunk, 0)
+		var httpErr *UmbrellaHTTPError
+		if !errors.As(err, &httpErr) {
+			t.Fatalf("submitDestinations() error = %v, want an UmbrellaHTTPError", err)
+		}
+		if *requests != 1 {
+			t.Errorf("made %d requests, want 1", *requests)
+		}
+	})
+
+	t.Run("bisection depth is capped", func(t *testing.T) {
+		connector, requests := newTestConnector(t, func(destinations []NewDestination) (int, string) {
+			return http.StatusBadRequest, "high_volume_list_domain"
+		})
+
+		large := make([]NewDestination, 1<<(maxBisectDepth+2))
+		for i := range large {
+			large[i] = NewDestination{Destination: "h" + strings.Repeat("x", i%50) + ".example.com"}
+		}
+		_, err := connector.submitDestinations(&DestinationList{ID: 1}, large, 0)
+		if err == nil {
+			t.Fatal("submitDestinations() succeeded, want an error once the depth is exceeded")
+		}
+		if *requests > maxBisectDepth+1 {
+			t.Errorf("made %d requests, want at most %d", *requests, maxBisectDepth+1)
+		}
+	})
+}
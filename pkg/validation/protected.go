@@ -0,0 +1,119 @@
+package validation
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Protected holds domains and networks that must never be blocked
+type Protected struct {
+	domains map[string]bool
+	cidrs   []*net.IPNet
+}
+
+func NewProtected(domains []string, cidrs []string) (*Protected, error) {
+	p := &Protected{domains: make(map[string]bool)}
+
+	for _, domain := range domains {
+		domain = normalizeDomain(domain)
+		if domain != "" {
+			p.domains[domain] = true
+		}
+	}
+
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid protected CIDR %q: %w", cidr, err)
+		}
+		p.cidrs = append(p.cidrs, network)
+	}
+
+	return p, nil
+}
+
+// Returns the protected domain or network that a destination would block.
+// A domain destination also blocks its subdomains, so blocking a parent of
+// a protected domain is refused too.
+func (p *Protected) Match(destination string) (string, bool) {
+	host, isURL := hostOf(destination)
+
+	if ip := net.ParseIP(host); ip != nil {
+		for _, network := range p.cidrs {
+			if network.Contains(ip) {
+				return network.String(), true
+			}
+		}
+		if _, _, err := net.ParseCIDR(destination); err == nil {
+			return p.matchNetwork(destination)
+		}
+		return "", false
+	}
+	if _, network, err := net.ParseCIDR(destination); err == nil {
+		return p.matchNetwork(network.String())
+	}
+
+	// The host or one of its parents is protected
+	for h := host; h != ""; {
+		if p.domains[h] {
+			return h, true
+		}
+		i := strings.Index(h, ".")
+		if i < 0 {
+			break
+		}
+		h = h[i+1:]
+	}
+
+	// The destination is a parent of a protected domain
+	if !isURL {
+		for domain := range p.domains {
+			if strings.HasSuffix(domain, "."+host) {
+				return domain, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// Matches a destination network that overlaps a protected network
+func (p *Protected) matchNetwork(cidr string) (string, bool) {
+	_, destination, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", false
+	}
+	for _, network := range p.cidrs {
+		if network.Contains(destination.IP) || destination.Contains(network.IP) {
+			return network.String(), true
+		}
+	}
+	return "", false
+}
+
+// Returns the lowercased host of a destination and whether it is a URL
+func hostOf(destination string) (string, bool) {
+	if strings.Contains(destination, "://") {
+		if u, err := url.Parse(destination); err == nil {
+			return strings.ToLower(u.Hostname()), true
+		}
+	}
+	if strings.Contains(destination, "/") {
+		if _, _, err := net.ParseCIDR(destination); err == nil {
+			return destination, false
+		}
+		host := strings.SplitN(destination, "/", 2)[0]
+		return strings.ToLower(host), true
+	}
+	return strings.TrimSuffix(strings.ToLower(destination), "."), false
+}
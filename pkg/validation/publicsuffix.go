@@ -0,0 +1,205 @@
+package validation
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/fileManager"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Rules in a complete list, used to reject downloads that are not the list
+const minSuffixRules = 1000
+
+type SuffixCheck int
+
+const (
+	// The destination can be blocked
+	SuffixOK SuffixCheck = iota
+	// The destination is a public suffix, blocking it blocks every domain under it
+	SuffixPublic
+	// The destination is a tenant of a shared hosting suffix, such as foo.github.io
+	SuffixSharedTenant
+)
+
+type suffixRules struct {
+	rules      map[string]bool
+	wildcards  map[string]bool
+	exceptions map[string]bool
+}
+
+// SuffixList answers Public Suffix List lookups. The list compiled into the
+// binary is used unless an updated copy has been downloaded to path.
+type SuffixList struct {
+	path  string
+	once  sync.Once
+	rules *suffixRules
+	err   error
+}
+
+func NewSuffixList(path string) *SuffixList {
+	return &SuffixList{path: path}
+}
+
+// Returns where the list is read from
+func (sl *SuffixList) Source() (string, error) {
+	rules, err := sl.load()
+	if err != nil {
+		return "", err
+	}
+	if rules == nil {
+		return "embedded", nil
+	}
+	return sl.path, nil
+}
+
+// Returns the public suffix of a domain and whether it is an ICANN suffix
+// rather than a privately run one
+func (sl *SuffixList) PublicSuffix(domain string) (string, bool, error) {
+	rules, err := sl.load()
+	if err != nil {
+		return "", false, err
+	}
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	if rules == nil {
+		suffix, icann := publicsuffix.PublicSuffix(domain)
+		return suffix, icann, nil
+	}
+	suffix, icann := rules.publicSuffix(domain)
+	return suffix, icann, nil
+}
+
+// Checks whether a destination is safe to block. URLs and IPs only block
+// themselves, so only domains are checked.
+func (sl *SuffixList) Check(destination string) (SuffixCheck, string, error) {
+	host, isURL := hostOf(destination)
+	if isURL || host == "" || net.ParseIP(host) != nil {
+		return SuffixOK, "", nil
+	}
+	if _, _, err := net.ParseCIDR(destination); err == nil {
+		return SuffixOK, "", nil
+	}
+
+	suffix, icann, err := sl.PublicSuffix(host)
+	if err != nil {
+		return SuffixOK, "", err
+	}
+	if suffix == host {
+		return SuffixPublic, suffix, nil
+	}
+	// Private suffixes are shared hosting domains. The default rule for
+	// unknown TLDs is neither ICANN nor dotted, so it is not one of them.
+	if !icann && strings.Contains(suffix, ".") && strings.Count(strings.TrimSuffix(host, "."+suffix), ".") == 0 {
+		return SuffixSharedTenant, suffix, nil
+	}
+	return SuffixOK, suffix, nil
+}
+
+func (sl *SuffixList) load() (*suffixRules, error) {
+	sl.once.Do(func() {
+		exists, err := fileManager.IsExists(sl.path)
+		if err != nil || !exists {
+			sl.err = err
+			return
+		}
+		file, err := os.Open(sl.path)
+		if err != nil {
+			sl.err = err
+			return
+		}
+		defer file.Close()
+		sl.rules, sl.err = parseSuffixRules(file)
+		if sl.err != nil {
+			sl.err = fmt.Errorf("error reading public suffix list %s: %w", sl.path, sl.err)
+		}
+	})
+	return sl.rules, sl.err
+}
+
+// Validates a downloaded list and saves it in place of the embedded list
+func (sl *SuffixList) Update(r io.Reader) (int, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	rules, err := parseSuffixRules(bytes.NewReader(content))
+	if err != nil {
+		return 0, err
+	}
+	count := len(rules.rules) + len(rules.wildcards) + len(rules.exceptions)
+	if count < minSuffixRules {
+		return 0, fmt.Errorf("public suffix list has only %d rules, expected at least %d", count, minSuffixRules)
+	}
+
+	err = fileManager.WriteFileAtomic(sl.path, content, 0644)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Parses public_suffix_list.dat. Rules after the BEGIN PRIVATE DOMAINS
+// marker are private suffixes.
+func parseSuffixRules(r io.Reader) (*suffixRules, error) {
+	rules := &suffixRules{
+		rules:      make(map[string]bool),
+		wildcards:  make(map[string]bool),
+		exceptions: make(map[string]bool),
+	}
+
+	icann := true
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "//") {
+			if strings.Contains(line, "===BEGIN PRIVATE DOMAINS===") {
+				icann = false
+			}
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		rule := strings.ToLower(strings.Fields(line)[0])
+
+		switch {
+		case strings.HasPrefix(rule, "!"):
+			rules.exceptions[rule[1:]] = icann
+		case strings.HasPrefix(rule, "*."):
+			rules.wildcards[rule[2:]] = icann
+		default:
+			rules.rules[rule] = icann
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Finds the longest matching rule. Without a match the last label is the
+// suffix, as with the embedded list.
+func (rules *suffixRules) publicSuffix(domain string) (string, bool) {
+	labels := strings.Split(domain, ".")
+	for i := range labels {
+		candidate := strings.Join(labels[i:], ".")
+		if icann, ok := rules.exceptions[candidate]; ok {
+			return strings.Join(labels[i+1:], "."), icann
+		}
+		if icann, ok := rules.rules[candidate]; ok {
+			return candidate, icann
+		}
+		if i+1 < len(labels) {
+			if icann, ok := rules.wildcards[strings.Join(labels[i+1:], ".")]; ok {
+				return candidate, icann
+			}
+		}
+	}
+	return labels[len(labels)-1], false
+}
@@ -0,0 +1,163 @@
+package validation
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/thegrumpyape/umbrellasync/pkg/fileManager"
+)
+
+// Ranking is a top sites list such as Tranco or the Umbrella Top 1M, imported
+// from a rank,domain CSV file into a data file
+type Ranking struct {
+	path       string
+	suffixList *SuffixList
+	once       sync.Once
+	ranks      map[string]int
+	err        error
+}
+
+func NewRanking(path string, suffixList *SuffixList) *Ranking {
+	return &Ranking{path: path, suffixList: suffixList}
+}
+
+// Reports whether a ranking has been imported
+func (r *Ranking) Available() (bool, error) {
+	ranks, err := r.load()
+	return ranks != nil, err
+}
+
+// Returns the best rank of a destination host or its registrable domain, so
+// a subdomain of a popular site counts as popular too. Tenants of shared
+// hosting suffixes are not ranked by their hosting provider.
+func (r *Ranking) Rank(destination string) (string, int, bool, error) {
+	ranks, err := r.load()
+	if err != nil || ranks == nil {
+		return "", 0, false, err
+	}
+
+	host, _ := hostOf(destination)
+	if host == "" {
+		return "", 0, false, nil
+	}
+	candidates := []string{host}
+
+	suffix, _, err := r.suffixList.PublicSuffix(host)
+	if err != nil {
+		return "", 0, false, err
+	}
+	if suffix != host {
+		labels := strings.Split(strings.TrimSuffix(host, "."+suffix), ".")
+		registrable := labels[len(labels)-1] + "." + suffix
+		if registrable != host {
+			candidates = append(candidates, registrable)
+		}
+	}
+
+	best, bestDomain := 0, ""
+	for _, candidate := range candidates {
+		if rank, ok := ranks[candidate]; ok && (best == 0 || rank < best) {
+			best, bestDomain = rank, candidate
+		}
+	}
+	return bestDomain, best, best != 0, nil
+}
+
+// Imports a rank,domain CSV file, replacing the previous ranking. A header
+// row is skipped.
+func (r *Ranking) Import(reader io.Reader) (int, error) {
+	ranks, err := parseRanking(reader)
+	if err != nil {
+		return 0, err
+	}
+	if len(ranks) == 0 {
+		return 0, errors.New("no ranked domains found, expected rank,domain rows")
+	}
+
+	domains := make([]string, 0, len(ranks))
+	for domain := range ranks {
+		domains = append(domains, domain)
+	}
+	sort.Slice(domains, func(i, j int) bool { return ranks[domains[i]] < ranks[domains[j]] })
+
+	var b bytes.Buffer
+	for _, domain := range domains {
+		fmt.Fprintf(&b, "%d,%s\n", ranks[domain], domain)
+	}
+	err = fileManager.WriteFileAtomic(r.path, b.Bytes(), 0644)
+	if err != nil {
+		return 0, err
+	}
+	return len(ranks), nil
+}
+
+func (r *Ranking) load() (map[string]int, error) {
+	r.once.Do(func() {
+		exists, err := fileManager.IsExists(r.path)
+		if err != nil || !exists {
+			r.err = err
+			return
+		}
+		file, err := os.Open(r.path)
+		if err != nil {
+			r.err = err
+			return
+		}
+		defer file.Close()
+		r.ranks, r.err = parseRanking(file)
+		if r.err != nil {
+			r.err = fmt.Errorf("error reading ranking %s: %w", r.path, r.err)
+		}
+	})
+	return r.ranks, r.err
+}
+
+// Parses rank,domain rows, keeping the best rank of each domain
+func parseRanking(reader io.Reader) (map[string]int, error) {
+	records := csv.NewReader(bufio.NewReader(reader))
+	records.FieldsPerRecord = -1
+	records.ReuseRecord = true
+
+	ranks := make(map[string]int)
+	for line := 1; ; line++ {
+		record, err := records.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 2 {
+			continue
+		}
+
+		rank, err := strconv.Atoi(strings.TrimSpace(record[0]))
+		if err != nil || rank <= 0 {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("invalid rank %q on line %d", record[0], line)
+		}
+		domain := normalizeDomain(record[1])
+		if domain == "" {
+			continue
+		}
+		if existing, ok := ranks[domain]; !ok || rank < existing {
+			ranks[domain] = rank
+		}
+	}
+	return ranks, nil
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}